@@ -1,6 +1,12 @@
 package main
 
 import (
+	"fmt"
+
+	"github.com/docker/go-units"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/httpauth"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +23,90 @@ func newCacheCommand() *cobra.Command {
 		newCacheImportCommand(),
 		newCacheExportCommand(),
 		newCacheCleanCommand(),
+		newCacheGCCommand(),
+		newCacheVerifyCommand(),
+		newCacheLsCommand(),
+		newCacheInspectCommand(),
+		newCacheServeCommand(),
 	)
 	return cmd
 }
+
+func newURLOpener(cmd *cobra.Command) (*urlopener.URLOpener, error) {
+	flags := cmd.Flags()
+	netrcFile, err := flags.GetString("netrc")
+	if err != nil {
+		return nil, err
+	}
+	credentialsFile, err := flags.GetString("credentials-file")
+	if err != nil {
+		return nil, err
+	}
+	creds, err := httpauth.Load(netrcFile, credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var tlsOpts urlopener.TLSOpts
+	if tlsOpts.CACertFiles, err = flags.GetStringSlice("cacert"); err != nil {
+		return nil, err
+	}
+	if tlsOpts.CertFile, err = flags.GetString("cert"); err != nil {
+		return nil, err
+	}
+	if tlsOpts.KeyFile, err = flags.GetString("key"); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := urlopener.NewTLSConfig(tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := flags.GetString("proxy")
+	if err != nil {
+		return nil, err
+	}
+	noProxy, err := flags.GetString("no-proxy")
+	if err != nil {
+		return nil, err
+	}
+	offline, err := flags.GetBool("offline")
+	if err != nil {
+		return nil, err
+	}
+	return urlopener.New(
+		urlopener.WithCredentials(creds),
+		urlopener.WithTLSConfig(tlsConfig),
+		urlopener.WithProxy(proxy, noProxy),
+		urlopener.WithOffline(offline),
+	), nil
+}
+
+func newCache(cmd *cobra.Command) (*cache.Cache, error) {
+	cacheStr, err := cmd.Flags().GetString("cache")
+	if err != nil {
+		return nil, err
+	}
+	urlOpener, err := newURLOpener(cmd)
+	if err != nil {
+		return nil, err
+	}
+	cacheOpts := []cache.Option{cache.WithURLOpener(urlOpener)}
+	roDirs, err := cmd.Flags().GetStringSlice("cache-ro")
+	if err != nil {
+		return nil, err
+	}
+	if len(roDirs) > 0 {
+		cacheOpts = append(cacheOpts, cache.WithReadOnlyLayers(roDirs...))
+	}
+	maxSizeStr, err := cmd.Flags().GetString("cache-max-size")
+	if err != nil {
+		return nil, err
+	}
+	if maxSizeStr != "" {
+		maxSize, err := units.RAMInBytes(maxSizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the cache max size %q: %w", maxSizeStr, err)
+		}
+		cacheOpts = append(cacheOpts, cache.WithMaxSize(maxSize))
+	}
+	return cache.New(cacheStr, cacheOpts...)
+}
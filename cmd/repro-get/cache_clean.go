@@ -1,8 +1,6 @@
 package main
 
 import (
-	"os"
-
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -21,11 +19,10 @@ func newCacheCleanCommand() *cobra.Command {
 }
 
 func cacheCleanAction(cmd *cobra.Command, args []string) error {
-	flags := cmd.Flags()
-	cacheStr, err := flags.GetString("cache")
+	cache, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
-	logrus.Infof("Removing %q", cacheStr)
-	return os.RemoveAll(cacheStr)
+	logrus.Infof("Removing the files in %q", cache.Dir())
+	return cache.Clean()
 }
@@ -1,43 +1,159 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filelink"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 func newCacheExportCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "export DIR",
-		Short:   "Export the cached package files to the specified dir",
-		Example: "  repro-get cache export .",
-		Args:    cobra.ExactArgs(1),
-		RunE:    cacheExportAction,
-
-		DisableFlagsInUseLine: true,
+		Use:   "export [flags] (DIR|--tar FILE)",
+		Short: "Export the cached package files to the specified dir, or to a tar stream",
+		Example: `  repro-get cache export .
+  repro-get cache export --format=oci-layout ./oci
+  repro-get cache export --link-mode=reflink .
+  repro-get cache export --tar - | ssh air-gapped-host repro-get cache import --tar -`,
+		Args: cacheExportImportArgs,
+		RunE: cacheExportAction,
 	}
+	flags := cmd.Flags()
+	flags.String("format", "flat", "Output format (flat, oci-layout). The flat format cannot export files with conflicting basenames")
+	flags.String("ref-name", "latest", "[oci-layout] Reference name to be annotated to the manifest")
+	flags.String("link-mode", string(filelink.ModeCopy), "Link mode (copy, hardlink, reflink, symlink). Falls back in the order of symlink, hardlink, reflink, and copy on a failure")
+	flags.String("tar", "", "Export to a deterministic tar stream (\"-\" for stdout) that contains the blobs and SHA256SUMS, instead of a dir")
 	return cmd
 }
 
+// cacheExportImportArgs validates the args of the cache export and import commands.
+// The args must be empty if --tar is specified.
+func cacheExportImportArgs(cmd *cobra.Command, args []string) error {
+	tarFile, err := cmd.Flags().GetString("tar")
+	if err != nil {
+		return err
+	}
+	if tarFile != "" {
+		return cobra.NoArgs(cmd, args)
+	}
+	if cmd.Name() == "export" {
+		return cobra.ExactArgs(1)(cmd, args)
+	}
+	return cobra.MinimumNArgs(1)(cmd, args)
+}
+
 func cacheExportAction(cmd *cobra.Command, args []string) error {
-	dir := args[0]
-	w := cmd.OutOrStdout()
-	hw := distro.NewHashWriter(w)
 	flags := cmd.Flags()
-	cacheStr, err := flags.GetString("cache")
+	format, err := flags.GetString("format")
 	if err != nil {
 		return err
 	}
-	cache, err := cache.New(cacheStr)
+	tarFile, err := flags.GetString("tar")
+	if err != nil {
+		return err
+	}
+	linkModeStr, err := flags.GetString("link-mode")
+	if err != nil {
+		return err
+	}
+	linkMode, err := filelink.ParseMode(linkModeStr)
+	if err != nil {
+		return err
+	}
+	linkModes := make(map[filelink.Mode]int)
+	exportOpts := []cache.ExportOption{
+		cache.WithLinkMode(linkMode),
+		cache.WithLinkReporter(func(_ string, used filelink.Mode) {
+			linkModes[used]++
+		}),
+	}
+	w := cmd.OutOrStdout()
+	hw := distro.NewHashWriter(w)
+	cache, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
-	exported, err := cache.Export(dir)
-	for basename, sha256sum := range exported {
-		if hwErr := hw(sha256sum, basename); hwErr != nil {
-			logrus.Warn(hwErr)
+	if tarFile != "" {
+		if flags.Changed("format") {
+			return errors.New("--format cannot be specified with --tar")
 		}
+		if flags.Changed("link-mode") {
+			return errors.New("--link-mode cannot be specified with --tar")
+		}
+		return cacheExportTar(cmd, cache, tarFile)
+	}
+	dir := args[0]
+	defer reportLinkModes(linkMode, linkModes)
+	switch format {
+	case "flat":
+		exported, err := cache.Export(dir, exportOpts...)
+		for basename, sha256sum := range exported {
+			if hwErr := hw(sha256sum, basename); hwErr != nil {
+				logrus.Warn(hwErr)
+			}
+		}
+		return err
+	case "oci-layout":
+		refName, err := flags.GetString("ref-name")
+		if err != nil {
+			return err
+		}
+		exported, err := cache.ExportOCILayout(dir, refName, exportOpts...)
+		for _, f := range exported {
+			basename := f.Basename
+			if basename == "" {
+				basename = "UNKNOWN-" + f.SHA256
+			}
+			if hwErr := hw(f.SHA256, basename); hwErr != nil {
+				logrus.Warn(hwErr)
+			}
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// reportLinkModes prints the link modes that were actually used.
+func reportLinkModes(requested filelink.Mode, used map[filelink.Mode]int) {
+	var ss []string
+	for _, mode := range filelink.Modes() {
+		if n := used[mode]; n > 0 {
+			ss = append(ss, fmt.Sprintf("%s: %d", mode, n))
+		}
+	}
+	if len(ss) == 0 {
+		return
+	}
+	msg := fmt.Sprintf("Exported files with the link modes: %s", strings.Join(ss, ", "))
+	if len(used) != 1 || used[requested] == 0 {
+		logrus.Warn(msg + fmt.Sprintf(" (requested: %s)", requested))
+		return
+	}
+	logrus.Info(msg)
+}
+
+func cacheExportTar(cmd *cobra.Command, c *cache.Cache, tarFile string) error {
+	if tarFile == "-" {
+		exported, err := c.ExportTar(cmd.OutOrStdout())
+		logrus.Infof("Exported %d files", len(exported))
+		return err
+	}
+	f, err := os.Create(tarFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	exported, err := c.ExportTar(f)
+	if err != nil {
+		return err
 	}
-	return err
+	logrus.Infof("Exported %d files to %q", len(exported), tarFile)
+	return f.Close()
 }
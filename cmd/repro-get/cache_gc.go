@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/spf13/cobra"
+)
+
+func newCacheGCCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc [flags] SHA256SUMS...",
+		Short: "Remove the cached files that are not referenced by the hash files",
+		Example: `  repro-get cache gc --dry-run SHA256SUMS-amd64 SHA256SUMS-arm64
+  repro-get cache gc SHA256SUMS-amd64 SHA256SUMS-arm64`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: cacheGCAction,
+	}
+	flags := cmd.Flags()
+	flags.Bool("dry-run", false, "Only print the number of the blobs and the bytes to be removed")
+	return cmd
+}
+
+func cacheGCAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	dryRun, err := flags.GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := filespec.NewFromFiles(args...)
+	if err != nil {
+		return err
+	}
+	var keep []string
+	for _, sp := range fileSpecs {
+		keep = append(keep, sp.SHA256)
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	res, err := c.GC(keep, cache.GCOpts{DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	if dryRun {
+		_, err = fmt.Fprintf(w, "Would remove %d blobs, freeing %d bytes\n", len(res.RemovedBlobs), res.FreedBytes)
+	} else {
+		_, err = fmt.Fprintf(w, "Removed %d blobs, freed %d bytes\n", len(res.RemovedBlobs), res.FreedBytes)
+	}
+	return err
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/sirupsen/logrus"
@@ -9,30 +11,77 @@ import (
 
 func newCacheImportCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "import [FILES]...",
-		Short:   "Import package files into the cache",
-		Example: "  repro-get cache import *.dpkg",
-		Args:    cobra.MinimumNArgs(1),
-		RunE:    cacheImportAction,
-
-		DisableFlagsInUseLine: true,
+		Use:   "import [flags] ([FILES]...|--tar FILE)",
+		Short: "Import package files into the cache",
+		Long: `Import package files into the cache.
+Directories containing the "oci-layout" file are imported as OCI image layouts (see "repro-get cache export --format=oci-layout").
+Tar streams created by "repro-get cache export --tar" can be imported with --tar.`,
+		Example: `  repro-get cache import *.dpkg
+  repro-get cache import ./oci
+  repro-get cache import --tar - <cache.tar`,
+		Args: cacheExportImportArgs,
+		RunE: cacheImportAction,
 	}
+	flags := cmd.Flags()
+	flags.String("tar", "", "Import a tar stream (\"-\" for stdin) created by 'repro-get cache export --tar'")
 	return cmd
 }
 
 func cacheImportAction(cmd *cobra.Command, args []string) error {
 	w := cmd.OutOrStdout()
 	hw := distro.NewHashWriter(w)
-	flags := cmd.Flags()
-	cacheStr, err := flags.GetString("cache")
+	c, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
-	cache, err := cache.New(cacheStr)
+	tarFile, err := cmd.Flags().GetString("tar")
 	if err != nil {
 		return err
 	}
-	imported, err := cache.Import(args...)
+	if tarFile != "" {
+		r := cmd.InOrStdin()
+		if tarFile != "-" {
+			f, err := os.Open(tarFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		imported, err := c.ImportTar(r)
+		for _, f := range imported {
+			if f.Basename == "" {
+				continue
+			}
+			if hwErr := hw(f.SHA256, f.Basename); hwErr != nil {
+				logrus.Warn(hwErr)
+			}
+		}
+		return err
+	}
+	var others []string
+	for _, f := range args {
+		if !cache.IsOCILayout(f) {
+			others = append(others, f)
+			continue
+		}
+		imported, err := c.ImportOCILayout(f)
+		for _, f := range imported {
+			if f.Basename == "" {
+				continue
+			}
+			if hwErr := hw(f.SHA256, f.Basename); hwErr != nil {
+				logrus.Warn(hwErr)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	imported, err := c.Import(others...)
 	for basename, sha256sum := range imported {
 		if hwErr := hw(sha256sum, basename); hwErr != nil {
 			logrus.Warn(hwErr)
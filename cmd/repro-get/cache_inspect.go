@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/spf13/cobra"
+)
+
+func newCacheInspectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "inspect SHA256...",
+		Short:   "Inspect cached files",
+		Example: "  repro-get cache inspect 35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    cacheInspectAction,
+	}
+	flags := cmd.Flags()
+	flags.Bool("json", false, "Enable JSON output")
+	addCacheEntryFilterFlags(flags)
+	return cmd
+}
+
+func cacheInspectAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	jsonFlag, err := flags.GetBool("json")
+	if err != nil {
+		return err
+	}
+	filter, err := newCacheEntryFilter(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	printed := 0
+	for _, arg := range args {
+		ent, err := c.Inspect(strings.TrimPrefix(arg, "sha256:"))
+		if err != nil {
+			return fmt.Errorf("failed to inspect %q: %w", arg, err)
+		}
+		if !filter.match(ent) {
+			continue
+		}
+		if jsonFlag {
+			b, err := json.MarshalIndent(ent, "", "    ")
+			if err != nil {
+				return err
+			}
+			if _, err = fmt.Fprintln(w, string(b)); err != nil {
+				return err
+			}
+			continue
+		}
+		if printed > 0 {
+			fmt.Fprintln(w)
+		}
+		printCacheEntry(w, ent)
+		printed++
+	}
+	return nil
+}
+
+func printCacheEntry(w io.Writer, ent *cache.Entry) {
+	format, name := cacheEntryPackage(ent)
+	fmt.Fprintln(w, "SHA256: "+ent.SHA256)
+	fmt.Fprintf(w, "Size: %s (%d bytes)\n", units.BytesSize(float64(ent.Size)), ent.Size)
+	fmt.Fprintln(w, "Last used: "+ent.LastUsed.Local().Format(time.RFC3339))
+	if m := ent.Metadata; m != nil {
+		if m.FirstFetched != nil {
+			fmt.Fprintln(w, "First fetched: "+m.FirstFetched.Local().Format(time.RFC3339))
+		}
+		fmt.Fprintln(w, "Basename: "+m.Basename)
+		if len(m.Basenames) > 1 {
+			fmt.Fprintln(w, "Basenames:")
+			for _, f := range m.Basenames {
+				fmt.Fprintln(w, "- "+f)
+			}
+		}
+	}
+	fmt.Fprintln(w, "Format: "+format)
+	if name != "" {
+		fmt.Fprintln(w, "Package: "+name)
+	}
+	fmt.Fprintln(w, "URLs:")
+	for _, u := range ent.URLs {
+		fmt.Fprintln(w, "- "+u)
+	}
+	if m := ent.Metadata; m != nil && len(m.Origins) > 0 {
+		fmt.Fprintln(w, "Origins:")
+		for _, o := range m.Origins {
+			s := "- " + o.URL
+			if o.Provider != "" {
+				s += " (provider: " + o.Provider + ")"
+			}
+			if o.FirstFetched != nil {
+				s += " (first fetched: " + o.FirstFetched.Local().Format(time.RFC3339) + ")"
+			}
+			fmt.Fprintln(w, s)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// packageFormatUnknown is the format of the files that are not known packages
+const packageFormatUnknown = "unknown"
+
+// packageFormats are the values of the --format flag
+var packageFormats = []string{filespec.FormatDpkg, filespec.FormatRPM, filespec.FormatAPK, filespec.FormatPacman, packageFormatUnknown}
+
+func newCacheLsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List the cached files",
+		Example: `  repro-get cache ls
+  repro-get cache ls --format=dpkg --name=hello --json`,
+		Args: cobra.NoArgs,
+		RunE: cacheLsAction,
+	}
+	flags := cmd.Flags()
+	flags.Bool("json", false, "Enable JSON output")
+	addCacheEntryFilterFlags(flags)
+	return cmd
+}
+
+func addCacheEntryFilterFlags(flags *pflag.FlagSet) {
+	flags.StringSlice("name", nil, "Filter by the package name")
+	flags.StringSlice("format", nil, "Filter by the package format ("+strings.Join(packageFormats, "|")+")")
+}
+
+type cacheEntryFilter struct {
+	names   map[string]struct{}
+	formats map[string]struct{}
+}
+
+func newCacheEntryFilter(cmd *cobra.Command) (*cacheEntryFilter, error) {
+	flags := cmd.Flags()
+	names, err := flags.GetStringSlice("name")
+	if err != nil {
+		return nil, err
+	}
+	formats, err := flags.GetStringSlice("format")
+	if err != nil {
+		return nil, err
+	}
+	f := &cacheEntryFilter{
+		names:   make(map[string]struct{}),
+		formats: make(map[string]struct{}),
+	}
+	for _, name := range names {
+		f.names[name] = struct{}{}
+	}
+	for _, format := range formats {
+		if !contains(packageFormats, format) {
+			return nil, fmt.Errorf("unknown package format %q (known formats: %v)", format, packageFormats)
+		}
+		f.formats[format] = struct{}{}
+	}
+	return f, nil
+}
+
+func (f *cacheEntryFilter) match(ent *cache.Entry) bool {
+	format, name := cacheEntryPackage(ent)
+	if len(f.formats) > 0 {
+		if _, ok := f.formats[format]; !ok {
+			return false
+		}
+	}
+	if len(f.names) > 0 {
+		if _, ok := f.names[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheEntryPackage returns the package format and the package name.
+func cacheEntryPackage(ent *cache.Entry) (format, name string) {
+	if ent.FileSpec == nil {
+		return packageFormatUnknown, ""
+	}
+	if p := ent.FileSpec.Package(); p != nil {
+		return p.Format, p.Name
+	}
+	return packageFormatUnknown, ""
+}
+
+func contains(ss []string, s string) bool {
+	for _, f := range ss {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheLsAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	jsonFlag, err := flags.GetBool("json")
+	if err != nil {
+		return err
+	}
+	filter, err := newCacheEntryFilter(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	ents, err := c.List()
+	if err != nil {
+		return err
+	}
+	var filtered []cache.Entry
+	for _, ent := range ents {
+		ent := ent
+		if filter.match(&ent) {
+			filtered = append(filtered, ent)
+		}
+	}
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		enc := json.NewEncoder(w)
+		for _, ent := range filtered {
+			if err = enc.Encode(ent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return printCacheEntries(w, filtered)
+}
+
+func printCacheEntries(w io.Writer, ents []cache.Entry) error {
+	tw := tabwriter.NewWriter(w, 4, 8, 4, ' ', 0)
+	fmt.Fprintln(tw, "SHA256\tSIZE\tFORMAT\tPACKAGE\tBASENAME\tURL")
+	for _, ent := range ents {
+		format, name := cacheEntryPackage(&ent)
+		basename, u := "-", "-"
+		if ent.Metadata != nil && ent.Metadata.Basename != "" {
+			basename = ent.Metadata.Basename
+		}
+		if name == "" {
+			name = "-"
+		}
+		if len(ent.URLs) > 0 {
+			u = ent.URLs[0]
+			if len(ent.URLs) > 1 {
+				u += fmt.Sprintf(" (+%d)", len(ent.URLs)-1)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", ent.SHA256, units.BytesSize(float64(ent.Size)), format, name, basename, u)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cacheserver"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve [flags] [SHA256SUMS]...",
+		Short: "Serve the cache over HTTP (read-only)",
+		Long: `Serve the cache over HTTP (read-only).
+
+The served cache can be used by other hosts as a provider, such as:
+$ repro-get --provider=http://HOST:8080/{{.Name}} install SHA256SUMS
+$ repro-get --provider=http://HOST:8080/sha256/{{.SHA256}} install SHA256SUMS
+
+The file names are resolved with the hash files if specified, otherwise with the basenames of the cached files.
+With --fall-through, the files in the hash files are fetched from the providers (--provider) on a cache miss of a GET request.
+`,
+		Example: `  repro-get cache serve --listen :8080
+  repro-get cache serve --listen :8080 --fall-through SHA256SUMS-amd64`,
+		Args: cobra.ArbitraryArgs,
+		RunE: cacheServeAction,
+	}
+	flags := cmd.Flags()
+	flags.String("listen", "127.0.0.1:8080", "Address to listen on")
+	flags.Bool("fall-through", false, "Fill the cache from the providers on a cache miss (needs the hash files)")
+	return cmd
+}
+
+func cacheServeAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	listen, err := flags.GetString("listen")
+	if err != nil {
+		return err
+	}
+	fallThrough, err := flags.GetBool("fall-through")
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	var opts []cacheserver.Option
+	if len(args) > 0 {
+		fileSpecs, err := filespec.NewFromFiles(args...)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, cacheserver.WithFileSpecs(fileSpecs))
+	}
+	if fallThrough {
+		if len(args) == 0 {
+			return errors.New("--fall-through needs the hash files")
+		}
+		providers, err := flags.GetStringSlice("provider")
+		if err != nil {
+			return err
+		}
+		if len(providers) == 0 {
+			d, err := getDistro(cmd)
+			if err != nil {
+				return err
+			}
+			providers = d.Info().DefaultProviders
+		}
+		opts = append(opts, cacheserver.WithFallThrough(providers))
+	}
+	handler, err := cacheserver.New(c, opts...)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	logrus.Infof("Serving the cache %q on %q", c.Dir(), listen)
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "verify",
+		Short:   "Verify the digests of the cached files, and the consistency of the metadata",
+		Example: "  repro-get cache verify",
+		Args:    cobra.NoArgs,
+		RunE:    cacheVerifyAction,
+	}
+	flags := cmd.Flags()
+	flags.Bool("quarantine", true, "Move corrupted files to the quarantine directory, so that they can be downloaded again")
+	return cmd
+}
+
+func cacheVerifyAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	quarantine, err := flags.GetBool("quarantine")
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	res, err := c.Verify(cache.VerifyOpts{Quarantine: quarantine})
+	if err != nil {
+		return err
+	}
+	for _, s := range res.Inconsistencies {
+		logrus.Warn(s)
+	}
+	w := cmd.OutOrStdout()
+	for _, sha256sum := range res.CorruptedBlobs {
+		if _, err = fmt.Fprintf(w, "CORRUPTED %s\n", sha256sum); err != nil {
+			return err
+		}
+	}
+	if _, err = fmt.Fprintf(w, "Verified %d blobs: %d corrupted, %d inconsistencies\n",
+		res.VerifiedBlobs, len(res.CorruptedBlobs), len(res.Inconsistencies)); err != nil {
+		return err
+	}
+	if len(res.CorruptedBlobs) > 0 {
+		return fmt.Errorf("found %d corrupted blobs", len(res.CorruptedBlobs))
+	}
+	return nil
+}
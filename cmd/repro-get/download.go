@@ -1,11 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
-	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/downloader"
-	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/envutil"
+	"github.com/reproducible-containers/repro-get/pkg/hashmerge"
+	"github.com/reproducible-containers/repro-get/pkg/progress"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func newDownloadCommand() *cobra.Command {
@@ -20,40 +26,139 @@ Use 'repro-get cache export' for exporting the cache.`,
 
 		DisableFlagsInUseLine: true,
 	}
-
+	addDownloaderFlags(cmd.Flags())
 	return cmd
 }
 
+// addDownloaderFlags adds the flags for downloader.Opts.
+// Used by both `repro-get download` and `repro-get install`.
+func addDownloaderFlags(flags *pflag.FlagSet) {
+	flags.Int("parallel", 1, "Number of files to download in parallel")
+	flags.Int("retries", 3, "Number of retries for transient errors, per provider")
+	flags.Duration("retry-backoff", downloader.DefaultRetryBackoff, "Initial backoff between retries, doubled on each retry")
+	flags.Duration("retry-max-backoff", downloader.DefaultRetryMaxBackoff, "Maximum backoff between retries")
+	flags.Bool("dry-run", false, "Print the plan as JSON, without downloading or installing anything")
+	flags.String("progress", "text", "Progress output format, \"text\" or \"json\" (\"json\" is printed to stderr)")
+	flags.Bool("require-signature", envutil.Bool("REPRO_GET_REQUIRE_SIGNATURE", false), "Refuse hash files without a valid signature (\"<FILE>.sig\") from a trusted key (--trusted-key) [$REPRO_GET_REQUIRE_SIGNATURE]")
+	addTrustedKeyFlags(flags)
+	// The later hash file wins by default, for the compatibility with the versions prior to the conflict detection
+	addPreferFlag(flags, hashmerge.PreferLast)
+}
+
+// newDownloaderOpts creates downloader.Opts from the flags.
+// SkipInstalled is not set.
+func newDownloaderOpts(cmd *cobra.Command) (*downloader.Opts, error) {
+	flags := cmd.Flags()
+	var (
+		opts downloader.Opts
+		err  error
+	)
+	opts.Providers, err = flags.GetStringSlice("provider")
+	if err != nil {
+		return nil, err
+	}
+	opts.Parallel, err = flags.GetInt("parallel")
+	if err != nil {
+		return nil, err
+	}
+	opts.Retries, err = flags.GetInt("retries")
+	if err != nil {
+		return nil, err
+	}
+	opts.RetryBackoff, err = flags.GetDuration("retry-backoff")
+	if err != nil {
+		return nil, err
+	}
+	opts.RetryMaxBackoff, err = flags.GetDuration("retry-max-backoff")
+	if err != nil {
+		return nil, err
+	}
+	opts.DryRun, err = flags.GetBool("dry-run")
+	if err != nil {
+		return nil, err
+	}
+	opts.Offline, err = flags.GetBool("offline")
+	if err != nil {
+		return nil, err
+	}
+	progressFormat, err := flags.GetString("progress")
+	if err != nil {
+		return nil, err
+	}
+	switch progressFormat {
+	case "text":
+		// Progress bars are not readable when multiple files are downloaded in parallel
+		opts.Reporter = progress.NewTextReporter(cmd.OutOrStdout(), opts.Parallel <= 1)
+	case "json":
+		// Not stdout, as stdout is shared with the output of the installer (e.g., `dpkg -i`)
+		opts.Reporter = progress.NewJSONReporter(cmd.ErrOrStderr())
+	default:
+		return nil, fmt.Errorf("unknown progress format %q (known formats: text, json)", progressFormat)
+	}
+	return &opts, nil
+}
+
+// Plan is printed by `--dry-run`.
+type Plan struct {
+	Files                   []downloader.PlanEntry `json:"Files"`
+	PackagesToBeInstalled   []string               `json:"PackagesToBeInstalled,omitempty"`
+	AuxFilesForInstallation []string               `json:"AuxFilesForInstallation,omitempty"`
+}
+
+func printPlan(w io.Writer, res *downloader.Result, install bool) error {
+	plan := Plan{
+		Files: res.Plan,
+	}
+	if install {
+		for _, f := range res.PackagesToBeInstalled {
+			plan.PackagesToBeInstalled = append(plan.PackagesToBeInstalled, f.Name)
+		}
+		for _, f := range res.AuxFilesForInstallation {
+			plan.AuxFilesForInstallation = append(plan.AuxFilesForInstallation, f.Name)
+		}
+	}
+	b, err := json.MarshalIndent(plan, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
 func downloadAction(cmd *cobra.Command, args []string) error {
 	d, err := getDistro(cmd)
 	if err != nil {
 		return err
 	}
 
-	opts := downloader.Opts{
-		SkipInstalled: false,
+	opts, err := newDownloaderOpts(cmd)
+	if err != nil {
+		return err
 	}
+	opts.SkipInstalled = false
 
-	ctx := cmd.Context()
-	flags := cmd.Flags()
-	cacheStr, err := flags.GetString("cache")
+	hashFiles, err := readHashFiles(cmd, args)
 	if err != nil {
 		return err
 	}
-	cache, err := cache.New(cacheStr)
+
+	ctx := cmd.Context()
+	cache, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
-	opts.Providers, err = flags.GetStringSlice("provider")
+
+	fileSpecs, err := mergeHashFiles(cmd, hashFiles)
 	if err != nil {
 		return err
 	}
 
-	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	res, err := downloader.Download(ctx, d, cache, fileSpecs, *opts)
 	if err != nil {
 		return err
 	}
-
-	_, err = downloader.Download(ctx, d, cache, fileSpecs, opts)
-	return err
+	if opts.DryRun {
+		return printPlan(cmd.OutOrStdout(), res, false)
+	}
+	return nil
 }
@@ -17,6 +17,12 @@ func newHashCommand() *cobra.Command {
 		newHashGenerateCommand(),
 		newHashUpdateCommand(),
 		newHashInspectCommand(),
+		newHashConvertCommand(),
+		newHashDiffCommand(),
+		newHashMergeCommand(),
+		newHashCheckCommand(),
+		newHashSignCommand(),
+		newHashVerifyCommand(),
 	)
 	return cmd
 }
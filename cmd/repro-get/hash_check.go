@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashcheck"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newHashCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check [flags] [SHA256SUMS]...",
+		Short: "Check whether the files are still available from the providers",
+		Long: `Check whether the files are still available from the providers, without downloading them.
+
+HTTP(S) providers are probed with HEAD (or a ranged GET when HEAD is rejected).
+OCI providers are probed by resolving the digest in the repository.
+
+Each file is reported as:
+- available:     available from a non-archival provider
+- archival-only: only available from archival providers, which are persistent but slow
+- unavailable:   not available from any provider
+
+The archival providers are shown in 'repro-get info'.
+Exits with an error if a file is unavailable (or archival-only, with --strict).`,
+		Example: "  repro-get hash check SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    hashCheckAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Int("parallel", 8, "Number of URLs to probe in parallel")
+	flags.StringSlice("archival-provider", nil, "Archival provider (default: the archival providers of the distro)")
+	flags.Bool("strict", false, "Exit with an error if a file is only available from archival providers")
+	flags.Bool("json", false, "Enable JSON output")
+	return cmd
+}
+
+func hashCheckAction(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	var opts hashcheck.Opts
+	if opts.Providers, err = flags.GetStringSlice("provider"); err != nil {
+		return err
+	}
+	if len(opts.Providers) == 0 {
+		opts.Providers = d.Info().DefaultProviders
+	}
+	if opts.ArchivalProviders, err = flags.GetStringSlice("archival-provider"); err != nil {
+		return err
+	}
+	if !flags.Changed("archival-provider") {
+		opts.ArchivalProviders = d.Info().ArchivalProviders
+	}
+	if opts.Parallel, err = flags.GetInt("parallel"); err != nil {
+		return err
+	}
+	strict, err := flags.GetBool("strict")
+	if err != nil {
+		return err
+	}
+	jsonFlag, err := flags.GetBool("json")
+	if err != nil {
+		return err
+	}
+	urlOpener, err := newURLOpener(cmd)
+	if err != nil {
+		return err
+	}
+	opts.Prober = urlOpener
+
+	fileSpecs, err := filespec.NewFromFiles(args...)
+	if err != nil {
+		return err
+	}
+	results, err := hashcheck.Check(ctx, fileSpecs, opts)
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		enc := json.NewEncoder(w)
+		for _, res := range results {
+			if err = enc.Encode(res); err != nil {
+				return err
+			}
+		}
+	} else if err = printHashCheckResults(w, results); err != nil {
+		return err
+	}
+
+	var unavailable, archivalOnly int
+	for _, res := range results {
+		switch res.Status {
+		case hashcheck.StatusUnavailable:
+			unavailable++
+		case hashcheck.StatusArchivalOnly:
+			archivalOnly++
+		}
+	}
+	if archivalOnly > 0 {
+		logrus.Warnf("%d file(s) are only available from archival providers", archivalOnly)
+	}
+	switch {
+	case unavailable > 0:
+		return fmt.Errorf("%d file(s) are not available from any provider", unavailable)
+	case strict && archivalOnly > 0:
+		return fmt.Errorf("%d file(s) are only available from archival providers", archivalOnly)
+	}
+	return nil
+}
+
+func printHashCheckResults(w io.Writer, results []hashcheck.Result) error {
+	tw := tabwriter.NewWriter(w, 4, 8, 4, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tPROVIDERS")
+	for _, res := range results {
+		var available int
+		for _, f := range res.Providers {
+			if f.Available {
+				available++
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\n", res.Name, res.Status, available, len(res.Providers))
+	}
+	return tw.Flush()
+}
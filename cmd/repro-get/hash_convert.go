@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	pkgepoch "github.com/containerd/containerd/pkg/epoch"
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	hashFormatSHA256SUMS = "sha256sums"
+	hashFormatLock       = "lock"
+)
+
+func newHashConvertCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "convert [flags] FILE",
+		Short: "Convert a hash file between the SHA256SUMS format and the lockfile format",
+		Long: `Convert a hash file between the SHA256SUMS format and the lockfile format.
+The file is written to stdout.
+
+When converting SHA256SUMS to a lockfile, the sizes are filled from the cache,
+and the epoch is taken from $SOURCE_DATE_EPOCH or the mtime of SHA256SUMS.
+
+When converting a lockfile to SHA256SUMS, the fields that cannot be represented in SHA256SUMS are lost,
+except the CIDs and the OCI refs.`,
+		Example: "  repro-get hash convert SHA256SUMS-" + archutil.OCIArchDashVariant() + " >SHA256SUMS-" + archutil.OCIArchDashVariant() + ".lock\n" +
+			"  repro-get hash convert --format=sha256sums SHA256SUMS-" + archutil.OCIArchDashVariant() + ".lock >SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.ExactArgs(1),
+		RunE: hashConvertAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("format", "", "Output format (sha256sums, lock). Defaults to the other format of the input")
+	return cmd
+}
+
+func hashConvertAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+	fname := args[0]
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	isLock := lockfile.IsLockfile(b)
+	if format == "" {
+		format = hashFormatLock
+		if isLock {
+			format = hashFormatSHA256SUMS
+		}
+	}
+	w := cmd.OutOrStdout()
+	switch format {
+	case hashFormatSHA256SUMS:
+		if !isLock {
+			_, err = w.Write(b)
+			return err
+		}
+		l, err := lockfile.Parse(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("failed to parse %q as a lockfile: %w", fname, err)
+		}
+		return l.WriteSHA256SUMS(w)
+	case hashFormatLock:
+		if isLock {
+			_, err = w.Write(b)
+			return err
+		}
+		sums, err := sha256sums.Parse(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("failed to parse %q as SHA256SUMS: %w", fname, err)
+		}
+		epoch, err := pkgepoch.SourceDateEpoch()
+		if err != nil {
+			return err
+		}
+		if epoch == nil {
+			st, err := os.Stat(fname)
+			if err != nil {
+				return err
+			}
+			mtime := st.ModTime().UTC().Truncate(time.Second)
+			epoch = &mtime
+		}
+		var distroName string
+		if flags.Changed("distro") {
+			if distroName, err = flags.GetString("distro"); err != nil {
+				return err
+			}
+		}
+		c, err := newCache(cmd)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to open the cache, the sizes are not filled")
+			c = nil
+		}
+		l, err := newLockfile(sums, distroName, epoch, c)
+		if err != nil {
+			return err
+		}
+		return l.Write(w)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// newLockfile creates a lockfile from the sha256sums map.
+// The sizes are filled from the cache, if c is non-nil.
+func newLockfile(sums map[string]string, distroName string, epoch *time.Time, c *cache.Cache) (*lockfile.Lockfile, error) {
+	if len(sums) == 0 {
+		return nil, errors.New("no hash")
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMS(sums)
+	if err != nil {
+		return nil, err
+	}
+	l := filespec.NewLockfile(fileSpecs)
+	l.Distro = distroName
+	l.Epoch = epoch
+	if c != nil {
+		for i := range l.Files {
+			f := &l.Files[i]
+			blob, err := c.BlobAbsPath(f.SHA256)
+			if err != nil {
+				return nil, err
+			}
+			if st, err := os.Stat(blob); err == nil {
+				f.Size = st.Size()
+			} else {
+				logrus.Debugf("Size of %q is unknown (not cached)", f.Name)
+			}
+		}
+	}
+	return l, nil
+}
+
+// newHashEpoch returns $SOURCE_DATE_EPOCH, or the current time truncated to seconds.
+func newHashEpoch() (*time.Time, error) {
+	epoch, err := pkgepoch.SourceDateEpoch()
+	if err != nil {
+		return nil, err
+	}
+	if epoch == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		epoch = &now
+	}
+	return epoch, nil
+}
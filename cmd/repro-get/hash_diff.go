@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashdiff"
+	"github.com/spf13/cobra"
+)
+
+func newHashDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [flags] OLD NEW",
+		Short: "Show the package changes between two hash files",
+		Long: `Show the package changes between two hash files.
+
+The packages are matched by the package format, the package name, and the architecture,
+and reported as "added", "removed", "upgraded", "downgraded", or "changed".
+The versions are compared with the ordering of each distribution.
+"changed" means that the digest changed without a version change.`,
+		Example: "  repro-get hash diff SHA256SUMS-" + archutil.OCIArchDashVariant() + ".old SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  git show HEAD~:SHA256SUMS-" + archutil.OCIArchDashVariant() + " >/tmp/old; repro-get hash diff --format=markdown /tmp/old SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.ExactArgs(2),
+		RunE: hashDiffAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("format", "text", "Output format (text, json, markdown)")
+	return cmd
+}
+
+func hashDiffAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+	before, err := filespec.NewFromFiles(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := filespec.NewFromFiles(args[1])
+	if err != nil {
+		return err
+	}
+	changes := hashdiff.Diff(before, after)
+	w := cmd.OutOrStdout()
+	switch format {
+	case "text":
+		return hashdiff.WriteText(w, changes)
+	case "json":
+		if changes == nil {
+			changes = []hashdiff.Change{}
+		}
+		b, err := json.MarshalIndent(changes, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "markdown":
+		return hashdiff.WriteMarkdown(w, changes)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
@@ -1,14 +1,12 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
-	"os"
 
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
-	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
-	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -17,15 +15,20 @@ func newHashGenerateCommand() *cobra.Command {
 		Use:   "generate [flags] [PACKAGES]... >SHA256SUMS",
 		Short: "Generate the hash file",
 		Long: `Generate the hash file.
-The file is written to stdout.`,
-		Example: "  repro-get hash generate >SHA256SUMS-" + archutil.OCIArchDashVariant(),
-		Args:    cobra.ArbitraryArgs,
-		RunE:    hashGenerateAction,
+The file is written to stdout.
+
+With --format=lock, a lockfile (JSON) is generated instead of SHA256SUMS.
+The lockfile also contains the sizes (of the cached files), the package identities, the epoch, and the distro.`,
+		Example: "  repro-get hash generate >SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get hash generate --format=lock >SHA256SUMS-" + archutil.OCIArchDashVariant() + ".lock",
+		Args: cobra.ArbitraryArgs,
+		RunE: hashGenerateAction,
 
 		DisableFlagsInUseLine: true,
 	}
 	flags := cmd.Flags()
-	flags.String("dedupe", "", "Skip generating entries that are already presend in the specified file")
+	flags.String("dedupe", "", "Skip generating entries that are already presend in the specified file (SHA256SUMS or lockfile)")
+	flags.String("format", hashFormatSHA256SUMS, "Output format (sha256sums, lock)")
 	return cmd
 }
 
@@ -43,39 +46,72 @@ func hashGenerateAction(cmd *cobra.Command, args []string) error {
 	}
 
 	if d.Info().CacheIsNeededForGeneratingHash {
-		cacheStr, err := flags.GetString("cache")
+		opts.Cache, err = newCache(cmd)
 		if err != nil {
 			return err
 		}
-		opts.Cache, err = cache.New(cacheStr)
+		opts.Offline, err = flags.GetBool("offline")
 		if err != nil {
 			return err
 		}
 	}
 
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
 	w := cmd.OutOrStdout()
-	hw := distro.NewHashWriter(w)
+	var hw distro.HashWriter
+	sums := make(map[string]string) // for hashFormatLock
+	switch format {
+	case hashFormatSHA256SUMS:
+		hw = distro.NewHashWriter(w)
+	case hashFormatLock:
+		hw = func(sha256sum, filename string) error {
+			sums[filename] = sha256sum
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
 
 	dedupeFile, err := flags.GetString("dedupe")
 	if err != nil {
 		return err
 	}
 	if dedupeFile != "" {
-		old, err := os.ReadFile(dedupeFile)
-		if err != nil {
-			return fmt.Errorf("failed to open %q: %w", dedupeFile, err)
-		}
-		oldSums, err := sha256sums.Parse(bytes.NewReader(old))
+		oldFileSpecs, err := filespec.NewFromFiles(dedupeFile)
 		if err != nil {
-			return fmt.Errorf("failed to parse %q as SHA256SUMS: %w", dedupeFile, err)
+			return err
 		}
 		hw0 := hw
 		hw = func(sha256sum, filename string) error {
-			if oldSums[filename] == sha256sum {
+			if old, ok := oldFileSpecs[filename]; ok && old.SHA256 == sha256sum {
 				return nil
 			}
 			return hw0(sha256sum, filename)
 		}
 	}
-	return d.GenerateHash(ctx, hw, opts)
+	if err = d.GenerateHash(ctx, hw, opts); err != nil {
+		return err
+	}
+	if format != hashFormatLock {
+		return nil
+	}
+	epoch, err := newHashEpoch()
+	if err != nil {
+		return err
+	}
+	c := opts.Cache
+	if c == nil {
+		if c, err = newCache(cmd); err != nil {
+			logrus.WithError(err).Warn("Failed to open the cache, the sizes are not filled")
+			c = nil
+		}
+	}
+	l, err := newLockfile(sums, d.Info().Name, epoch, c)
+	if err != nil {
+		return err
+	}
+	return l.Write(w)
 }
@@ -24,7 +24,7 @@ func newHashInspectCommand() *cobra.Command {
 }
 
 func hashInspectAction(cmd *cobra.Command, args []string) error {
-	entries, err := filespec.NewFromSHA256SUMSFiles(args...)
+	entries, err := filespec.NewFromFiles(args...)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashmerge"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+func newHashMergeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge [flags] FILE...",
+		Short: "Merge hash files into a single file",
+		Long: `Merge hash files into a single file.
+The file is written to stdout, sorted by the file name.
+
+A conflict is a package (same format, name, and architecture) that has different versions or digests across the hash files.
+Conflicts are resolved with --prefer:
+- newest: prefer the newest version (and then the hash file with the newest epoch, and then the first hash file)
+- first:  prefer the first hash file
+- last:   prefer the last hash file
+- fail:   fail on conflicts (default)
+
+The same conflict detection is also done by 'repro-get install' and 'repro-get download',
+with --prefer=last by default.`,
+		Example: "  repro-get hash merge --prefer=newest SHA256SUMS-base SHA256SUMS-service >SHA256SUMS",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    hashMergeAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	addPreferFlag(flags, hashmerge.PreferFail)
+	flags.String("format", "", "Output format (sha256sums, lock). Defaults to the format of the first file")
+	return cmd
+}
+
+func hashMergeAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+	// The distro is taken from the first lockfile that has it, unless --distro is specified
+	var distroName string
+	if flags.Changed("distro") {
+		if distroName, err = flags.GetString("distro"); err != nil {
+			return err
+		}
+	}
+	hashFiles := make([]*hashFile, len(args))
+	for i, fname := range args {
+		if hashFiles[i], err = readHashFile(fname); err != nil {
+			return err
+		}
+		b := hashFiles[i].content
+		if !lockfile.IsLockfile(b) {
+			continue
+		}
+		if i == 0 && format == "" {
+			format = hashFormatLock
+		}
+		if distroName == "" {
+			l, err := lockfile.Parse(bytes.NewReader(b))
+			if err != nil {
+				return fmt.Errorf("failed to parse %q as a lockfile: %w", fname, err)
+			}
+			distroName = l.Distro
+		}
+	}
+	if format == "" {
+		format = hashFormatSHA256SUMS
+	}
+
+	fileSpecs, err := mergeHashFiles(cmd, hashFiles)
+	if err != nil {
+		return err
+	}
+	if len(fileSpecs) == 0 {
+		return errors.New("no hash")
+	}
+	l := filespec.NewLockfile(fileSpecs)
+	w := cmd.OutOrStdout()
+	switch format {
+	case hashFormatSHA256SUMS:
+		return l.WriteSHA256SUMS(w)
+	case hashFormatLock:
+		l.Distro = distroName
+		// The newest epoch is used, as the merged files are expected to be available at that time
+		for _, sp := range fileSpecs {
+			if sp.Epoch != nil && (l.Epoch == nil || sp.Epoch.After(*l.Epoch)) {
+				epoch := sp.Epoch.UTC().Truncate(time.Second)
+				l.Epoch = &epoch
+			}
+		}
+		return l.Write(w)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
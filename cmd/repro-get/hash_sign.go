@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/envutil"
+	"github.com/reproducible-containers/repro-get/pkg/sshsig"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+func newHashSignCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sign [flags] [SHA256SUMS]...",
+		Short: "Sign the hash files with an ed25519 SSH key",
+		Long: `Sign the hash files with an ed25519 SSH key.
+The signature is written to "<FILE>.sig", in the SSH signature format with the namespace "` + sshsig.Namespace + `".
+The signature is compatible with 'ssh-keygen -Y sign -n ` + sshsig.Namespace + `'.`,
+		Example: "  repro-get hash sign --signing-key ~/.ssh/id_ed25519 SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    hashSignAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("signing-key", envutil.String("REPRO_GET_SIGNING_KEY", ""), "Private key file (OpenSSH ed25519, unencrypted) [$REPRO_GET_SIGNING_KEY]")
+	return cmd
+}
+
+func hashSignAction(cmd *cobra.Command, args []string) error {
+	keyFile, err := cmd.Flags().GetString("signing-key")
+	if err != nil {
+		return err
+	}
+	if keyFile == "" {
+		return errors.New("no signing key specified (Hint: specify --signing-key)")
+	}
+	keyB, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.ParsePrivateKey(keyB)
+	if err != nil {
+		var passphraseMissingErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseMissingErr) {
+			return fmt.Errorf("encrypted key %q is not supported (Hint: use 'ssh-keygen -Y sign -n %s -f %s FILE' instead)", keyFile, sshsig.Namespace, keyFile)
+		}
+		return fmt.Errorf("failed to parse %q: %w", keyFile, err)
+	}
+	for _, fname := range args {
+		b, err := os.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		sig, err := sshsig.Sign(signer, bytes.NewReader(b), sshsig.Namespace)
+		if err != nil {
+			return fmt.Errorf("failed to sign %q: %w", fname, err)
+		}
+		sigFile := fname + signatureSuffix
+		if err = os.WriteFile(sigFile, sig, 0644); err != nil {
+			return err
+		}
+		logrus.Infof("Signed %q with %s: %q", fname, sshsig.Fingerprint(signer.PublicKey()), sigFile)
+	}
+	return nil
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
 	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -41,11 +43,24 @@ func hashUpdateAction(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to open %q: %w", hashFile, err)
 	}
-	sums, err := sha256sums.Parse(bytes.NewReader(old))
-	if err != nil {
-		return fmt.Errorf("failed to parse %q as SHA256SUMS: %w", hashFile, err)
+	var (
+		fileSpecs map[string]*filespec.FileSpec
+		oldLock   *lockfile.Lockfile
+	)
+	if lockfile.IsLockfile(old) {
+		oldLock, err = lockfile.Parse(bytes.NewReader(old))
+		if err != nil {
+			return fmt.Errorf("failed to parse %q as a lockfile: %w", hashFile, err)
+		}
+		fileSpecs, err = filespec.NewFromLockfile(oldLock)
+	} else {
+		var sums map[string]string
+		sums, err = sha256sums.Parse(bytes.NewReader(old))
+		if err != nil {
+			return fmt.Errorf("failed to parse %q as SHA256SUMS: %w", hashFile, err)
+		}
+		fileSpecs, err = filespec.NewFromSHA256SUMS(sums)
 	}
-	fileSpecs, err := filespec.NewFromSHA256SUMS(sums)
 	if err != nil {
 		return err
 	}
@@ -65,9 +80,21 @@ func hashUpdateAction(cmd *cobra.Command, args []string) error {
 	}
 	var b bytes.Buffer
 	hw := distro.NewHashWriter(&b)
+	sums := make(map[string]string) // for oldLock
+	if oldLock != nil {
+		hw = func(sha256sum, filename string) error {
+			sums[filename] = sha256sum
+			return nil
+		}
+	}
 	if err := d.GenerateHash(ctx, hw, opts); err != nil {
 		return err
 	}
+	if oldLock != nil {
+		if err = updateLockfile(&b, cmd, d, oldLock, sums); err != nil {
+			return err
+		}
+	}
 	if b.Len() == 0 {
 		return errors.New("no hash was generated")
 	}
@@ -79,3 +106,50 @@ func hashUpdateAction(cmd *cobra.Command, args []string) error {
 	fmt.Fprintln(cmd.OutOrStdout(), cmp.Diff(string(old), string(neu)))
 	return os.WriteFile(hashFile, neu, 0644)
 }
+
+// updateLockfile writes the lockfile generated from sums to w.
+// The distro, the CIDs, and the OCI refs are carried over from the old lockfile.
+// The epoch is updated only when the files are changed.
+func updateLockfile(w io.Writer, cmd *cobra.Command, d distro.Distro, old *lockfile.Lockfile, sums map[string]string) error {
+	if len(sums) == 0 {
+		return nil
+	}
+	distroName := old.Distro
+	if distroName == "" {
+		distroName = d.Info().Name
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to open the cache, the sizes are not filled")
+		c = nil
+	}
+	l, err := newLockfile(sums, distroName, old.Epoch, c)
+	if err != nil {
+		return err
+	}
+	oldFiles := make(map[string]lockfile.File, len(old.Files))
+	for _, f := range old.Files {
+		oldFiles[f.SHA256] = f
+	}
+	changed := len(l.Files) != len(old.Files)
+	for i := range l.Files {
+		f := &l.Files[i]
+		oldF, ok := oldFiles[f.SHA256]
+		if !ok || oldF.Name != f.Name {
+			changed = true
+		}
+		if !ok {
+			continue
+		}
+		f.CID, f.OCIRef = oldF.CID, oldF.OCIRef
+		if f.Size == 0 {
+			f.Size = oldF.Size
+		}
+	}
+	if changed {
+		if l.Epoch, err = newHashEpoch(); err != nil {
+			return err
+		}
+	}
+	return l.Write(w)
+}
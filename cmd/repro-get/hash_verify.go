@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/envutil"
+	"github.com/reproducible-containers/repro-get/pkg/sshsig"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/crypto/ssh"
+)
+
+// signatureSuffix is the suffix of the signature file of a hash file.
+const signatureSuffix = ".sig"
+
+func newHashVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify [flags] [SHA256SUMS]...",
+		Short: "Verify the signatures of the hash files",
+		Long: `Verify the signatures of the hash files.
+The signature is read from "<FILE>.sig" (see 'repro-get hash sign').`,
+		Example: "  repro-get hash verify --trusted-key ~/.ssh/id_ed25519.pub SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    hashVerifyAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	addTrustedKeyFlags(cmd.Flags())
+	return cmd
+}
+
+// addTrustedKeyFlags adds the --trusted-key flag.
+// Used by `repro-get hash verify`, `repro-get download`, and `repro-get install`.
+func addTrustedKeyFlags(flags *pflag.FlagSet) {
+	flags.StringSlice("trusted-key", envutil.StringSlice("REPRO_GET_TRUSTED_KEY", nil),
+		"Trusted ed25519 public keys for the hash file signatures. A public key file (authorized_keys or allowed_signers format), or an inline \"ssh-ed25519 AAAA...\" string [$REPRO_GET_TRUSTED_KEY]")
+}
+
+func hashVerifyAction(cmd *cobra.Command, args []string) error {
+	trusted, err := trustedKeys(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	for _, fname := range args {
+		f, err := readHashFile(fname)
+		if err != nil {
+			return err
+		}
+		pub, err := verifyHashFile(f, trusted)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: OK (%s)\n", fname, sshsig.Fingerprint(pub))
+	}
+	return nil
+}
+
+// trustedKeys loads the --trusted-key values.
+func trustedKeys(flags *pflag.FlagSet) ([]ssh.PublicKey, error) {
+	ss, err := flags.GetStringSlice("trusted-key")
+	if err != nil {
+		return nil, err
+	}
+	var res []ssh.PublicKey
+	for _, s := range ss {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		b := []byte(s)
+		if !strings.HasPrefix(s, "ssh-") {
+			b, err = os.ReadFile(s)
+			if err != nil {
+				return res, fmt.Errorf("failed to read the trusted key file: %w", err)
+			}
+		}
+		keys, err := sshsig.ParsePublicKeys(b)
+		if err != nil {
+			return res, fmt.Errorf("failed to parse the trusted key %q: %w", s, err)
+		}
+		res = append(res, keys...)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no trusted key specified (Hint: specify --trusted-key)")
+	}
+	return res, nil
+}
+
+// verifyHashFile verifies the signature of the content of the hash file, and returns the public key that made the signature.
+// The content is not read from the file again, so that the verified content is the content to be parsed.
+func verifyHashFile(f *hashFile, trusted []ssh.PublicKey) (ssh.PublicKey, error) {
+	sigFile := f.name + signatureSuffix
+	sig, err := os.ReadFile(sigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the signature of %q: %w", f.name, err)
+	}
+	pub, err := sshsig.Verify(bytes.NewReader(f.content), sig, sshsig.Namespace, trusted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %q with %q: %w", f.name, sigFile, err)
+	}
+	return pub, nil
+}
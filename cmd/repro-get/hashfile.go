@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashmerge"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/reproducible-containers/repro-get/pkg/sshsig"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/crypto/ssh"
+)
+
+// hashFileAppender appends the pseudo lines ("<SHA256>  /ipfs/<CID>", "<SHA256>  /oci/<REF>") to the hash file.
+// For a lockfile, the pseudo lines are converted to the CID and OCIRef fields,
+// and the lockfile is rewritten on Close.
+type hashFileAppender struct {
+	fname  string
+	f      *os.File           // SHA256SUMS
+	lock   *lockfile.Lockfile // lockfile
+	closed bool
+}
+
+func newHashFileAppender(fname string) (*hashFileAppender, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	a := &hashFileAppender{
+		fname: fname,
+	}
+	if lockfile.IsLockfile(b) {
+		a.lock, err = lockfile.Parse(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q as a lockfile: %w", fname, err)
+		}
+		return a, nil
+	}
+	a.f, err = os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q with O_WRONLY|O_APPEND: %w", fname, err)
+	}
+	return a, nil
+}
+
+func (a *hashFileAppender) Append(line string) error {
+	if a.f != nil {
+		_, err := fmt.Fprintln(a.f, line)
+		return err
+	}
+	sha256sum, name, err := sha256sums.ParseLine(line)
+	if err != nil {
+		return err
+	}
+	pseudo := filespec.ParsePseudoFilename(name)
+	if pseudo == nil {
+		return fmt.Errorf("expected a pseudo file name, got %q", name)
+	}
+	for i := range a.lock.Files {
+		f := &a.lock.Files[i]
+		if f.SHA256 != sha256sum {
+			continue
+		}
+		if pseudo.CID != "" {
+			f.CID = pseudo.CID
+		}
+		if pseudo.OCIRef != "" {
+			f.OCIRef = pseudo.OCIRef
+		}
+	}
+	return nil
+}
+
+// Close can be called multiple times.
+func (a *hashFileAppender) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	if a.f != nil {
+		return a.f.Close()
+	}
+	var b bytes.Buffer
+	if err := a.lock.Write(&b); err != nil {
+		return err
+	}
+	return os.WriteFile(a.fname, b.Bytes(), 0o644)
+}
+
+// addPreferFlag adds the --prefer flag for mergeHashFiles.
+func addPreferFlag(flags *pflag.FlagSet, def hashmerge.Prefer) {
+	flags.String("prefer", string(def), fmt.Sprintf("Strategy for resolving the package conflicts across the hash files %v", hashmerge.Prefers()))
+}
+
+// hashFile is a hash file (SHA256SUMS or lockfile) read into the memory.
+type hashFile struct {
+	name    string
+	content []byte
+	modTime time.Time
+}
+
+// readHashFile reads the hash file.
+func readHashFile(fname string) (*hashFile, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return &hashFile{name: fname, content: b, modTime: st.ModTime()}, nil
+}
+
+// readHashFiles reads the hash files, and verifies their signatures if --require-signature is specified.
+// Each file is read only once, so that the file cannot be replaced between the verification and the parsing.
+func readHashFiles(cmd *cobra.Command, fnames []string) ([]*hashFile, error) {
+	flags := cmd.Flags()
+	required, err := flags.GetBool("require-signature")
+	if err != nil {
+		return nil, err
+	}
+	var trusted []ssh.PublicKey
+	if required {
+		if trusted, err = trustedKeys(flags); err != nil {
+			return nil, err
+		}
+	}
+	res := make([]*hashFile, len(fnames))
+	for i, fname := range fnames {
+		if res[i], err = readHashFile(fname); err != nil {
+			return nil, err
+		}
+		if required {
+			pub, err := verifyHashFile(res[i], trusted)
+			if err != nil {
+				return nil, err
+			}
+			logrus.Infof("Verified the signature of %q (%s)", fname, sshsig.Fingerprint(pub))
+		}
+	}
+	return res, nil
+}
+
+// mergeHashFiles parses the hash files, and merges them with the package-level conflict detection.
+// The conflicts are resolved with the --prefer flag.
+func mergeHashFiles(cmd *cobra.Command, files []*hashFile) (map[string]*filespec.FileSpec, error) {
+	preferStr, err := cmd.Flags().GetString("prefer")
+	if err != nil {
+		return nil, err
+	}
+	prefer, err := hashmerge.ParsePrefer(preferStr)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]hashmerge.Input, len(files))
+	for i, f := range files {
+		fileSpecs, err := filespec.NewFromBytes(f.content, f.modTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", f.name, err)
+		}
+		inputs[i] = hashmerge.Input{Name: f.name, FileSpecs: fileSpecs}
+	}
+	merged, conflicts, err := hashmerge.Merge(inputs, prefer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge the hash files: %w (Hint: try --prefer=newest, --prefer=first, or --prefer=last)", err)
+	}
+	for _, c := range conflicts {
+		logrus.Warnf("Conflict: %s (chose %s)", c, c.Candidates[c.Chosen].Input)
+	}
+	return merged, nil
+}
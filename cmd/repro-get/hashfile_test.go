@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/hashmerge"
+	"github.com/spf13/cobra"
+	"gotest.tools/v3/assert"
+)
+
+func TestMergeHashFilesDefault(t *testing.T) {
+	dir := t.TempDir()
+	sha := func(c string) string { return strings.Repeat(c, 64) }
+	base := filepath.Join(dir, "SHA256SUMS.base")
+	assert.NilError(t, os.WriteFile(base, []byte(sha("0")+"  pool/main/h/hello/hello_2.10-3_amd64.deb\n"), 0644))
+	extra := filepath.Join(dir, "SHA256SUMS.extra")
+	assert.NilError(t, os.WriteFile(extra, []byte(sha("1")+"  pool/main/h/hello/hello_2.10-2_amd64.deb\n"), 0644))
+	var files []*hashFile
+	for _, fname := range []string{base, extra} {
+		f, err := readHashFile(fname)
+		assert.NilError(t, err)
+		files = append(files, f)
+	}
+
+	// The later hash file wins for install and download, as in the versions prior to the conflict detection
+	for _, cmd := range []*cobra.Command{newInstallCommand(), newDownloadCommand()} {
+		prefer, err := cmd.Flags().GetString("prefer")
+		assert.NilError(t, err)
+		assert.Equal(t, string(hashmerge.PreferLast), prefer, cmd.Name())
+		merged, err := mergeHashFiles(cmd, files)
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(merged))
+		assert.Equal(t, sha("1"), merged["pool/main/h/hello/hello_2.10-2_amd64.deb"].SHA256)
+	}
+
+	// hash merge fails on conflicts by default
+	_, err := mergeHashFiles(newHashMergeCommand(), files)
+	assert.ErrorContains(t, err, "conflict")
+}
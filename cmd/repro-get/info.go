@@ -56,6 +56,9 @@ func infoAction(cmd *cobra.Command, args []string) error {
 	fmt.Fprintln(w, "Distro: "+info.Distro.Name)
 	fmt.Fprintln(w, "Default providers:")
 	for _, f := range info.Distro.DefaultProviders {
+		if contains(info.Distro.ArchivalProviders, f) {
+			f += " (archival)"
+		}
 		fmt.Fprintln(w, "- "+f)
 	}
 	return nil
@@ -1,11 +1,12 @@
 package main
 
 import (
+	"time"
+
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
-	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/downloader"
-	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/progress"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -20,6 +21,9 @@ func newInstallCommand() *cobra.Command {
 
 		DisableFlagsInUseLine: true,
 	}
+	flags := cmd.Flags()
+	addDownloaderFlags(flags)
+	flags.Bool("verify-cache", false, "Re-hash the cached files before installing them, and download them again if they are corrupted")
 	return cmd
 }
 
@@ -29,35 +33,39 @@ func installAction(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	ctx := cmd.Context()
-	flags := cmd.Flags()
 
-	downloadOpts := downloader.Opts{
-		SkipInstalled: true,
+	downloadOpts, err := newDownloaderOpts(cmd)
+	if err != nil {
+		return err
 	}
-
-	downloadOpts.Providers, err = flags.GetStringSlice("provider")
+	downloadOpts.SkipInstalled = true
+	downloadOpts.VerifyCache, err = cmd.Flags().GetBool("verify-cache")
 	if err != nil {
 		return err
 	}
 
-	cacheStr, err := flags.GetString("cache")
+	hashFiles, err := readHashFiles(cmd, args)
 	if err != nil {
 		return err
 	}
-	cache, err := cache.New(cacheStr)
+
+	cache, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
 
-	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	fileSpecs, err := mergeHashFiles(cmd, hashFiles)
 	if err != nil {
 		return err
 	}
 
-	downloadRes, err := downloader.Download(ctx, d, cache, fileSpecs, downloadOpts)
+	downloadRes, err := downloader.Download(ctx, d, cache, fileSpecs, *downloadOpts)
 	if err != nil {
 		return err
 	}
+	if downloadOpts.DryRun {
+		return printPlan(cmd.OutOrStdout(), downloadRes, true)
+	}
 	if len(downloadRes.PackagesToBeInstalled) == 0 {
 		logrus.Info("No package to install")
 		return nil
@@ -66,5 +74,24 @@ func installAction(cmd *cobra.Command, args []string) error {
 	installOpts := distro.InstallOpts{
 		AuxFiles: downloadRes.AuxFilesForInstallation,
 	}
-	return d.InstallPackages(ctx, cache, downloadRes.PackagesToBeInstalled, installOpts)
+	reporter := downloadOpts.Reporter
+	pkgs := downloadRes.PackagesToBeInstalled
+	reporter.Report(progress.Event{
+		Type:     progress.EventInstallStarted,
+		Time:     time.Now(),
+		Packages: len(pkgs),
+	})
+	startedAt := time.Now()
+	err = d.InstallPackages(ctx, cache, pkgs, installOpts)
+	ev := progress.Event{
+		Type:     progress.EventInstallFinished,
+		Time:     time.Now(),
+		Packages: len(pkgs),
+		Duration: time.Since(startedAt),
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	reporter.Report(ev)
+	return err
 }
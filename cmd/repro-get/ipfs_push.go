@@ -2,12 +2,10 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"strings"
 
-	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -45,16 +43,12 @@ func ipfsPushAction(cmd *cobra.Command, args []string) error {
 
 	flags := cmd.Flags()
 	hashFile := args[0]
-	cacheStr, err := flags.GetString("cache")
-	if err != nil {
-		return err
-	}
-	cache, err := cache.New(cacheStr)
+	cache, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
 
-	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(hashFile)
+	fileSpecs, err := filespec.NewFromFiles(hashFile)
 	if err != nil {
 		return err
 	}
@@ -67,11 +61,11 @@ func ipfsPushAction(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	var appender io.WriteCloser
+	var appender *hashFileAppender
 	if appendFlag {
-		appender, err = os.OpenFile(hashFile, os.O_WRONLY|os.O_APPEND, 0o644)
+		appender, err = newHashFileAppender(hashFile)
 		if err != nil {
-			return fmt.Errorf("failed to open %q with O_WRONLY|O_APPEND: %w", hashFile, err)
+			return err
 		}
 		defer appender.Close()
 	}
@@ -101,10 +95,13 @@ func ipfsPushAction(cmd *cobra.Command, args []string) error {
 			return err
 		}
 		if appender != nil {
-			if _, err = fmt.Fprintln(appender, newLine); err != nil {
+			if err = appender.Append(newLine); err != nil {
 				return err
 			}
 		}
 	}
+	if appender != nil {
+		return appender.Close()
+	}
 	return nil
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/reproducible-containers/repro-get/pkg/distro/none"
 	"github.com/reproducible-containers/repro-get/pkg/distro/ubuntu"
 	"github.com/reproducible-containers/repro-get/pkg/envutil"
+	"github.com/reproducible-containers/repro-get/pkg/httpauth"
 	"github.com/reproducible-containers/repro-get/pkg/version"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -94,6 +95,16 @@ func newRootCommand() *cobra.Command {
 	flags := cmd.PersistentFlags()
 	flags.Bool("debug", envutil.Bool("DEBUG", false), "debug mode [$DEBUG]")
 	flags.String("cache", envutil.String("REPRO_GET_CACHE", "/var/cache/repro-get"), "Cache directory [$REPRO_GET_CACHE]")
+	flags.StringSlice("cache-ro", envutil.StringSlice("REPRO_GET_CACHE_RO", nil), "Read-only cache directories, looked up in order after --cache [$REPRO_GET_CACHE_RO]")
+	flags.String("cache-max-size", envutil.String("REPRO_GET_CACHE_MAX_SIZE", ""), "Max size of the cache, such as \"10GiB\". The least recently used files are evicted. [$REPRO_GET_CACHE_MAX_SIZE]")
+	flags.String("netrc", httpauth.DefaultNetrcFile(), "netrc file for HTTP(S) providers [$NETRC]")
+	flags.String("credentials-file", envutil.String("REPRO_GET_CREDENTIALS_FILE", httpauth.DefaultCredentialsFile()), "Credentials file for HTTP(S) providers [$REPRO_GET_CREDENTIALS_FILE]")
+	flags.StringSlice("cacert", envutil.StringSlice("REPRO_GET_CACERT", nil), "CA certificate files (PEM) to trust, in addition to the system ones [$REPRO_GET_CACERT]")
+	flags.String("cert", envutil.String("REPRO_GET_CERT", ""), "Client certificate file (PEM) [$REPRO_GET_CERT]")
+	flags.String("key", envutil.String("REPRO_GET_KEY", ""), "Client key file (PEM) [$REPRO_GET_KEY]")
+	flags.String("proxy", "", "Proxy URL for HTTP(S) and OCI providers (default: $HTTPS_PROXY, $HTTP_PROXY)")
+	flags.String("no-proxy", "", "Comma-separated hosts to be excluded from the proxy (default: $NO_PROXY)")
+	flags.Bool("offline", envutil.Bool("REPRO_GET_OFFLINE", false), "Offline mode: only use the cache and file:// providers [$REPRO_GET_OFFLINE]")
 
 	defaultDistro, err := getDistroByName("")
 	if err != nil {
@@ -121,6 +132,7 @@ func newRootCommand() *cobra.Command {
 		newHashCommand(),
 		newCacheCommand(),
 		newIPFSCommand(),
+		newOCICommand(),
 		newDockerfileCommand(),
 	)
 	return cmd
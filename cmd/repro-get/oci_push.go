@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	refdocker "github.com/containerd/containerd/reference/docker"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/ociartifact"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newOCIPushCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "push [flags] SHA256SUMS REF",
+		Short: "Push the files into an OCI registry",
+		Long: `Push the files into an OCI registry as an artifact, and append the OCI ref to the hash file.
+The registry credential is read from ~/.docker/config.json .
+
+REF is a reference such as "ghcr.io/USERNAME/dpkgs:latest".
+Use "oci+http://" prefix to disable HTTPS.
+
+There is no 'repro-get oci pull' command.
+To pull the pushed packages, set the provider to an {{.OCIRef}} template string, such as:
+$ repro-get --provider=oci://{{.OCIRef}} install
+`,
+		Example: "  repro-get oci push SHA256SUMS ghcr.io/USERNAME/dpkgs:latest",
+		Args:    cobra.ExactArgs(2),
+		RunE:    ociPushAction,
+
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.Bool("append", true, "Append the OCI ref to the hash file")
+
+	return cmd
+}
+
+func ociPushAction(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	stdout := cmd.OutOrStdout()
+
+	flags := cmd.Flags()
+	hashFile, rawRef := args[0], args[1]
+	if !strings.Contains(rawRef, "://") {
+		rawRef = "oci://" + rawRef
+	}
+	u, err := url.Parse(rawRef)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "oci", "oci+http", "oci+https":
+	default:
+		return fmt.Errorf("expected oci://, oci+http://, or oci+https, got %q", u.Scheme)
+	}
+	cache, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	urlOpener, err := newURLOpener(cmd)
+	if err != nil {
+		return err
+	}
+	resolver, ref, err := urlOpener.OCIResolver(ctx, u)
+	if err != nil {
+		return err
+	}
+	if _, ok := ref.(refdocker.Digested); ok {
+		return fmt.Errorf("expected a tag, got a digest %q", ref)
+	}
+	ociRef := ref.Name() // without the tag
+
+	fileSpecs, err := filespec.NewFromFiles(hashFile)
+	if err != nil {
+		return err
+	}
+
+	appendFlag, err := flags.GetBool("append")
+	if err != nil {
+		return err
+	}
+
+	fnames := make([]string, 0, len(fileSpecs))
+	for fname := range fileSpecs {
+		fnames = append(fnames, fname)
+	}
+	sort.Strings(fnames)
+	var (
+		files    []ociartifact.File
+		newLines []string
+	)
+	seen := make(map[string]struct{})
+	for _, fname := range fnames {
+		fileSpec := fileSpecs[fname]
+		if _, ok := seen[fileSpec.SHA256]; ok {
+			continue
+		}
+		seen[fileSpec.SHA256] = struct{}{}
+		blobPath, err := cache.BlobAbsPath(fileSpec.SHA256)
+		if err != nil {
+			return err
+		}
+		st, err := os.Stat(blobPath)
+		if err != nil {
+			return fmt.Errorf("uncached file? %q: %w (Hint: try 'repro-get download ...')", fname, err)
+		}
+		files = append(files, ociartifact.File{
+			SHA256:   fileSpec.SHA256,
+			Size:     st.Size(),
+			Basename: fileSpec.Basename,
+		})
+		if fileSpec.OCIRef == ociRef {
+			logrus.Infof("Skipping to append the OCI ref for %q (Already has OCI ref %q)", fname, fileSpec.OCIRef)
+			continue
+		}
+		newLines = append(newLines, fmt.Sprintf("%s  /oci/%s", fileSpec.SHA256, ociRef))
+	}
+
+	open := func(f ociartifact.File) (io.ReadCloser, error) {
+		blobPath, err := cache.BlobAbsPath(f.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(blobPath)
+	}
+	logrus.Infof("Pushing %d files to %q", len(files), ref)
+	manifestDesc, err := ociartifact.Push(ctx, resolver, ref.String(), files, open)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Pushed %s@%s", ref, manifestDesc.Digest)
+
+	var appender *hashFileAppender
+	if appendFlag && len(newLines) > 0 {
+		appender, err = newHashFileAppender(hashFile)
+		if err != nil {
+			return err
+		}
+		defer appender.Close()
+	}
+	for _, newLine := range newLines {
+		if _, err = fmt.Fprintln(stdout, newLine); err != nil {
+			return err
+		}
+		if appender != nil {
+			if err = appender.Append(newLine); err != nil {
+				return err
+			}
+		}
+	}
+	if appender != nil {
+		return appender.Close()
+	}
+	return nil
+}
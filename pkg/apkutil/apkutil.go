@@ -32,3 +32,136 @@ func Split(pkgDashVer string) (*APK, error) {
 	}
 	return nil, fmt.Errorf("failed to split %q into the package name and the version string", pkgDashVer)
 }
+
+// apkSuffixes are the version suffixes in the order of precedence.
+// A version without a suffix sorts between "_rc" and "_cvs".
+var apkSuffixes = []string{"alpha", "beta", "pre", "rc", "", "cvs", "svn", "git", "hg", "p"}
+
+type apkVersion struct {
+	nums     []string
+	letter   byte
+	suffixes [][2]string // {name, number}
+	revision string
+}
+
+func parseVersion(s string) (*apkVersion, error) {
+	var v apkVersion
+	if i := strings.LastIndex(s, "-r"); i >= 0 {
+		v.revision = s[i+2:]
+		if !isNumber(v.revision) {
+			return nil, fmt.Errorf("invalid revision in %q", s)
+		}
+		s = s[:i]
+	}
+	var suffixes []string
+	if i := strings.Index(s, "_"); i >= 0 {
+		suffixes = strings.Split(s[i+1:], "_")
+		s = s[:i]
+	}
+	if s != "" && 'a' <= s[len(s)-1] && s[len(s)-1] <= 'z' {
+		v.letter = s[len(s)-1]
+		s = s[:len(s)-1]
+	}
+	v.nums = strings.Split(s, ".")
+	for _, f := range v.nums {
+		if !isNumber(f) {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+	}
+	for _, f := range suffixes {
+		name := strings.TrimRight(f, "0123456789")
+		if suffixRank(name) < 0 {
+			return nil, fmt.Errorf("invalid suffix %q in %q", f, s)
+		}
+		v.suffixes = append(v.suffixes, [2]string{name, f[len(name):]})
+	}
+	return &v, nil
+}
+
+func suffixRank(name string) int {
+	for i, f := range apkSuffixes {
+		if f == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func compareNumbers(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CompareVersions compares the version strings such as "20220614-r0",
+// and returns -1, 0, or 1.
+func CompareVersions(a, b string) (int, error) {
+	aV, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bV, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return compareVersions(aV, bV), nil
+}
+
+func compareVersions(aV, bV *apkVersion) int {
+	for i := 0; i < len(aV.nums) && i < len(bV.nums); i++ {
+		if c := compareNumbers(aV.nums[i], bV.nums[i]); c != 0 {
+			return c
+		}
+	}
+	if c := compareInts(len(aV.nums), len(bV.nums)); c != 0 {
+		return c
+	}
+	if c := compareInts(int(aV.letter), int(bV.letter)); c != 0 {
+		return c
+	}
+	noSuffix := [2]string{"", ""}
+	for i := 0; i < len(aV.suffixes) || i < len(bV.suffixes); i++ {
+		aS, bS := noSuffix, noSuffix
+		if i < len(aV.suffixes) {
+			aS = aV.suffixes[i]
+		}
+		if i < len(bV.suffixes) {
+			bS = bV.suffixes[i]
+		}
+		if c := compareInts(suffixRank(aS[0]), suffixRank(bS[0])); c != 0 {
+			return c
+		}
+		if c := compareNumbers(aS[1], bS[1]); c != 0 {
+			return c
+		}
+	}
+	return compareNumbers(aV.revision, bV.revision)
+}
@@ -15,3 +15,32 @@ func TestParseFilename(t *testing.T) {
 	}
 	assert.DeepEqual(t, expected, got)
 }
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"20220614-r0", "20220614-r0", 0},
+		{"20220614-r0", "20220614-r1", -1},
+		{"20220614-r10", "20220614-r9", 1},
+		{"1.2.3-r0", "1.2.10-r0", -1},
+		{"1.2-r0", "1.2.0-r0", -1},
+		{"1.2a-r0", "1.2-r0", 1},
+		{"1.2_rc1-r0", "1.2-r0", -1},
+		{"1.2_alpha-r0", "1.2_beta-r0", -1},
+		{"1.2_p1-r0", "1.2-r0", 1},
+		{"1.2_git20230101-r0", "1.2_git20221231-r0", 1},
+	}
+	for _, tc := range testCases {
+		got, err := CompareVersions(tc.a, tc.b)
+		assert.NilError(t, err)
+		assert.Equal(t, tc.expected, got, "%q vs %q", tc.a, tc.b)
+		got, err = CompareVersions(tc.b, tc.a)
+		assert.NilError(t, err)
+		assert.Equal(t, -tc.expected, got, "%q vs %q", tc.b, tc.a)
+	}
+
+	_, err := CompareVersions("1.2-r0", "1.2-rX")
+	assert.ErrorContains(t, err, "invalid revision")
+}
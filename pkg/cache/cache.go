@@ -4,16 +4,23 @@
 //
 //   - blobs/sha256/*.tmp:    tmp files
 //
+//   - blobs/sha256/.download-<SHA256>.tmp: partially downloaded blobs, resumed by the next download
+//
 //   - blobs/sha256/<SHA256>: verified blobs
 //
 //   - metadata/sha256/<SHA256> : metadata of the blob (optional)
 //
 //   - digests/by-url-sha256/<SHA256-OF-URL> : digest of the blob (optional, note that URL is not always unique)
+//
+//   - quarantine/sha256/<SHA256>: corrupted blobs, moved from blobs/sha256 by Quarantine
+//
+//   - locks/cache.lock: cache-wide lock (shared for adding blobs, exclusive for removing blobs)
+//
+//   - locks/sha256/<SHA256>.lock: per-blob lock for downloading the blob
 package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -21,64 +28,107 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/containerd/continuity/fs"
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/opencontainers/go-digest"
-	"github.com/reproducible-containers/repro-get/pkg/progressbar"
+	"github.com/reproducible-containers/repro-get/pkg/filelink"
+	"github.com/reproducible-containers/repro-get/pkg/progress"
 	"github.com/reproducible-containers/repro-get/pkg/urlopener"
 	"github.com/sirupsen/logrus"
 )
 
-type Metadata struct {
-	Basename string
-}
+const (
+	BlobsSHA256RelPath      = "blobs/sha256"
+	MetadataSHA256RelPath   = "metadata/sha256"
+	ReverseURLRelPath       = "digests/by-url-sha256"
+	QuarantineSHA256RelPath = "quarantine/sha256"
+	CacheLockRelPath        = "locks/cache.lock"
+	BlobLocksSHA256RelPath  = "locks/sha256"
+)
 
-func ValidateMetadata(m *Metadata) error {
-	if m == nil {
-		return nil
-	}
-	if filepath.Base(m.Basename) != m.Basename {
-		return fmt.Errorf("invalid basename: %q", m.Basename)
+type Option func(c *Cache)
+
+// WithURLOpener specifies the URL opener.
+func WithURLOpener(o *urlopener.URLOpener) Option {
+	return func(c *Cache) {
+		c.urlOpener = o
 	}
-	return nil
 }
 
-const (
-	BlobsSHA256RelPath    = "blobs/sha256"
-	MetadataSHA256RelPath = "metadata/sha256"
-	ReverseURLRelPath     = "digests/by-url-sha256"
-)
+// WithReadOnlyLayers specifies read-only cache directories, such as a pre-warmed cache on an NFS share.
+// The read-only layers are looked up in order, after the writable layer (the dir argument of New).
+// The read-only layers are never written, and never locked.
+// New blobs are always added to the writable layer.
+func WithReadOnlyLayers(dirs ...string) Option {
+	return func(c *Cache) {
+		c.roDirs = append(c.roDirs, dirs...)
+	}
+}
 
-func New(dir string) (*Cache, error) {
+func New(dir string, options ...Option) (*Cache, error) {
 	if os.PathSeparator != '/' {
 		return nil, fmt.Errorf("expected os.PathSeparator to be '/', got %c", os.PathSeparator)
 	}
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
-	for _, f := range []string{BlobsSHA256RelPath, MetadataSHA256RelPath, ReverseURLRelPath} {
-		subDir := filepath.Join(dir, f) // no need to use securejoin (const)
-		if err := os.MkdirAll(subDir, 0755); err != nil {
-			return nil, err
-		}
-	}
 	c := &Cache{
 		dir:       dir,
 		urlOpener: urlopener.New(),
+		pinned:    make(map[string]struct{}),
+		size:      -1,
+		roMasked:  make(map[string]struct{}),
+	}
+	for _, f := range options {
+		f(c)
+	}
+	for _, roDir := range c.roDirs {
+		if _, err := os.Stat(filepath.Join(roDir, BlobsSHA256RelPath)); err != nil { // no need to use securejoin (const)
+			return nil, fmt.Errorf("invalid read-only layer %q: %w", roDir, err)
+		}
+	}
+	if err := c.mkdirAll(); err != nil {
+		return nil, err
 	}
 	return c, nil
 }
 
+func (c *Cache) mkdirAll() error {
+	for _, f := range []string{BlobsSHA256RelPath, MetadataSHA256RelPath, ReverseURLRelPath, BlobLocksSHA256RelPath} {
+		subDir := filepath.Join(c.dir, f) // no need to use securejoin (const)
+		if err := os.MkdirAll(subDir, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type Cache struct {
 	dir       string
 	urlOpener *urlopener.URLOpener
+
+	roDirs   []string
+	roMu     sync.Mutex
+	roMasked map[string]struct{} // key: sha256sum of the corrupted blobs in the read-only layers
+
+	maxSize int64
+	evictMu sync.Mutex
+	pinned  map[string]struct{} // key: sha256sum
+	size    int64               // total size of the blobs, -1 if unknown
 }
 
+// Dir returns the writable layer.
 func (c *Cache) Dir() string {
 	return c.dir
 }
 
+// ReadOnlyLayers returns the read-only layers.
+func (c *Cache) ReadOnlyLayers() []string {
+	return c.roDirs
+}
+
 // BlobRelPath returns a clean relative path like "blobs/sha256/<SHA256>".
 // The caller should append this path to c.Dir().
 // The returned path may not exist.
@@ -90,7 +140,28 @@ func (c *Cache) BlobRelPath(sha256sum string) (string, error) {
 	return securejoin.SecureJoin(BlobsSHA256RelPath, sha256sum)
 }
 
+// BlobAbsPath returns the absolute path of the blob.
+// The writable layer is looked up first, and then the read-only layers in order.
+// If the blob is not found in any layer, the path in the writable layer is returned.
 func (c *Cache) BlobAbsPath(sha256sum string) (string, error) {
+	blob, err := c.writableBlobAbsPath(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	if len(c.roDirs) == 0 {
+		return blob, nil
+	}
+	if _, err := os.Stat(blob); err == nil {
+		return blob, nil
+	}
+	if roBlob := c.readOnlyBlobAbsPath(sha256sum); roBlob != "" {
+		return roBlob, nil
+	}
+	return blob, nil
+}
+
+// writableBlobAbsPath returns the absolute path of the blob in the writable layer.
+func (c *Cache) writableBlobAbsPath(sha256sum string) (string, error) {
 	rel, err := c.BlobRelPath(sha256sum)
 	if err != nil {
 		return "", err
@@ -98,6 +169,28 @@ func (c *Cache) BlobAbsPath(sha256sum string) (string, error) {
 	return filepath.Join(c.dir, rel), nil // no need to use securejoin (rel is verified)
 }
 
+// readOnlyBlobAbsPath returns the absolute path of the blob in the first read-only layer that has the blob.
+// Returns an empty string if not found.
+func (c *Cache) readOnlyBlobAbsPath(sha256sum string) string {
+	rel, err := c.BlobRelPath(sha256sum)
+	if err != nil {
+		return ""
+	}
+	c.roMu.Lock()
+	_, masked := c.roMasked[sha256sum]
+	c.roMu.Unlock()
+	if masked {
+		return ""
+	}
+	for _, roDir := range c.roDirs {
+		roBlob := filepath.Join(roDir, rel) // no need to use securejoin (rel is verified)
+		if _, err := os.Stat(roBlob); err == nil {
+			return roBlob
+		}
+	}
+	return ""
+}
+
 func (c *Cache) MetadataFileRelPath(sha256sum string) (string, error) {
 	if err := digest.SHA256.Validate(sha256sum); err != nil {
 		return "", err
@@ -114,8 +207,9 @@ func (c *Cache) MetadataFileAbsPath(sha256sum string) (string, error) {
 }
 
 func (c *Cache) ReverseURLFileRelPath(u *url.URL) (string, error) {
-	// u.Redacted is used for consistency with the URL files
-	sha256OfURL := digest.SHA256.FromBytes([]byte(u.Redacted())).Encoded()
+	// The userinfo is stripped, so that the credentials are not stored in the cache,
+	// and so that the same file is found regardless of the credentials
+	sha256OfURL := digest.SHA256.FromBytes([]byte(urlopener.Redacted(u))).Encoded()
 	return securejoin.SecureJoin(ReverseURLRelPath, sha256OfURL)
 }
 
@@ -141,73 +235,238 @@ func (c *Cache) Cached(sha256sum string) (bool, error) {
 	return true, nil
 }
 
-func (c *Cache) Ensure(ctx context.Context, u *url.URL, sha256sum string, m *Metadata) error {
+type ensureOpts struct {
+	reporter progress.Reporter
+	provider string
+}
+
+type EnsureOption func(o *ensureOpts)
+
+// WithReporter sets the reporter of the download events.
+// Defaults to progress.Nop.
+func WithReporter(reporter progress.Reporter) EnsureOption {
+	return func(o *ensureOpts) {
+		o.reporter = reporter
+	}
+}
+
+// WithProvider sets the provider template string that produced the URL.
+// Recorded in the metadata (Origin.Provider).
+func WithProvider(provider string) EnsureOption {
+	return func(o *ensureOpts) {
+		o.provider = provider
+	}
+}
+
+func (c *Cache) Ensure(ctx context.Context, u *url.URL, sha256sum string, m *Metadata, options ...EnsureOption) error {
+	opts := ensureOpts{
+		reporter: progress.Nop,
+	}
+	for _, o := range options {
+		o(&opts)
+	}
 	if err := ValidateMetadata(m); err != nil {
 		return err
 	}
-	blob, err := c.BlobAbsPath(sha256sum) // also verifies sha256sum string representation
+	blob, err := c.writableBlobAbsPath(sha256sum) // also verifies sha256sum string representation
 	if err != nil {
 		return err
 	}
+	unlockCache, err := c.lockCache(false)
+	if err != nil {
+		return err
+	}
+	defer unlockCache()
+	unlock, err := c.lockBlob(sha256sum)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if _, err := os.Stat(blob); err == nil {
 		// sha256sum is verified on the initial caching
+		c.touchAndPin(sha256sum)
+		// The blob may have been cached under another basename, or from another origin
+		if err = c.writeMetadataFiles(sha256sum, u, m, opts.provider); err != nil {
+			logrus.WithError(err).Warnf("Failed to update the metadata of %s", sha256sum)
+		}
 		return nil
 	} else if !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
+	if c.readOnlyBlobAbsPath(sha256sum) != "" {
+		// The read-only layers are never written
+		return nil
+	}
 
-	tmpW, err := os.CreateTemp(filepath.Dir(blob), ".download-*.tmp")
+	partial := filepath.Join(filepath.Dir(blob), ".download-"+sha256sum+".tmp") // no need to use securejoin (sha256sum is verified)
+	tmpW, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		tmpW.Close()
-		os.Remove(tmpW.Name())
+		// Keep non-empty partial files for resuming
+		if st, err := os.Stat(partial); err == nil && st.Size() == 0 {
+			os.Remove(partial)
+		}
 	}()
-
-	r, sz, err := c.urlOpener.Open(ctx, u, sha256sum)
+	st, err := tmpW.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to open URL %q: %w", u.Redacted(), err)
+		return err
+	}
+	ev, start, err := c.download(ctx, u, sha256sum, tmpW, st.Size(), opts.reporter)
+	if err != nil && start > 0 && errors.Is(err, errDigestMismatch) {
+		// The partial file was broken, not the file on the URL
+		logrus.WithError(err).Warnf("Failed to resume the download of %q, downloading again from the beginning", urlopener.Redacted(u))
+		ev, _, err = c.download(ctx, u, sha256sum, tmpW, 0, opts.reporter)
 	}
-	defer r.Close()
-
-	bar, err := progressbar.New(sz)
 	if err != nil {
 		return err
 	}
 
+	if err = tmpW.Sync(); err != nil {
+		return err
+	}
+	if err = tmpW.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(partial, blob); err != nil {
+		return err
+	}
+	if err := c.writeMetadataFiles(sha256sum, u, m, opts.provider); err != nil {
+		return err
+	}
+	c.blobAdded(sha256sum, ev.Bytes)
+	opts.reporter.Report(eventWithType(ev, progress.EventDownloadFinished, time.Now()))
+	return nil
+}
+
+// errDigestMismatch is returned by download when the digest does not match.
+var errDigestMismatch = errors.New("digest mismatch")
+
+// download downloads u into the partial file tmpW, resuming from offset if supported by the URL.
+// The returned int64 is the offset that the download was actually resumed from.
+// On a digest mismatch, tmpW is truncated so that it is not resumed again.
+// The caller must hold the blob lock.
+func (c *Cache) download(ctx context.Context, u *url.URL, sha256sum string, tmpW *os.File, offset int64, reporter progress.Reporter) (progress.Event, int64, error) {
+	var ev progress.Event
+	r, start, sz, err := c.urlOpener.OpenAt(ctx, u, sha256sum, offset)
+	if err != nil {
+		return ev, 0, fmt.Errorf("failed to open URL %q: %w", urlopener.Redacted(u), err)
+	}
+	defer r.Close()
+
 	digester := digest.SHA256.Digester()
 	hasher := digester.Hash()
+	if _, err = tmpW.Seek(0, io.SeekStart); err != nil {
+		return ev, start, err
+	}
+	if start > 0 {
+		logrus.Debugf("Resuming the download of %q from %d bytes", urlopener.Redacted(u), start)
+		// Hash the existing prefix, so that the digest covers the whole file
+		if _, err = io.CopyN(hasher, tmpW, start); err != nil {
+			return ev, start, fmt.Errorf("failed to read the partial file %q: %w", tmpW.Name(), err)
+		}
+	} else if offset > 0 {
+		logrus.Debugf("Restarting the download of %q (resuming is not supported)", urlopener.Redacted(u))
+	}
+	if err = tmpW.Truncate(start); err != nil {
+		return ev, start, err
+	}
 	mw := io.MultiWriter(tmpW, hasher)
 
-	bar.Start()
-	if _, err = io.Copy(mw, bar.NewProxyReader(r)); err != nil {
-		return fmt.Errorf("failed to copy %d bytes: %w", sz, err)
+	ev = progress.Event{
+		SHA256:     sha256sum,
+		URL:        urlopener.Redacted(u),
+		Bytes:      start,
+		TotalBytes: sz,
+	}
+	startedAt := time.Now()
+	reporter.Report(eventWithType(ev, progress.EventDownloadStarted, startedAt))
+	written, err := io.Copy(mw, progress.NewProxyReader(r, reporter, ev))
+	ev.Bytes += written
+	ev.Duration = time.Since(startedAt)
+	if err != nil {
+		err = fmt.Errorf("failed to copy %d bytes: %w", sz-start, err)
+		ev.Error = err.Error()
+		reporter.Report(eventWithType(ev, progress.EventDownloadFinished, time.Now()))
+		return ev, start, err
 	}
-	bar.Finish()
 
 	actualSHA256SUM := digester.Digest().Encoded()
 	if actualSHA256SUM != sha256sum {
-		return fmt.Errorf("expected sha256sum %q, got %q", sha256sum, actualSHA256SUM)
+		// The partial file is broken, so it must not be resumed
+		if err = tmpW.Truncate(0); err != nil {
+			logrus.WithError(err).Warnf("Failed to truncate %q", tmpW.Name())
+		}
+		err = fmt.Errorf("%w: expected sha256sum %q, got %q", errDigestMismatch, sha256sum, actualSHA256SUM)
+		ev.Error = err.Error()
+		reporter.Report(eventWithType(ev, progress.EventDownloadFinished, time.Now()))
+		return ev, start, err
 	}
+	return ev, start, nil
+}
 
-	if err = tmpW.Sync(); err != nil {
-		return err
+func eventWithType(ev progress.Event, typ progress.EventType, tm time.Time) progress.Event {
+	ev.Type = typ
+	ev.Time = tm
+	return ev
+}
+
+type exportOpts struct {
+	linkMode     filelink.Mode
+	linkReporter func(sha256sum string, used filelink.Mode)
+}
+
+func newExportOpts(options []ExportOption) exportOpts {
+	opts := exportOpts{
+		linkMode: filelink.ModeCopy,
 	}
-	if err = tmpW.Close(); err != nil {
-		return err
+	for _, o := range options {
+		o(&opts)
 	}
-	if err = os.Rename(tmpW.Name(), blob); err != nil {
-		return err
+	return opts
+}
+
+type ExportOption func(o *exportOpts)
+
+// WithLinkMode sets the link mode of the exported files.
+// Defaults to filelink.ModeCopy.
+// The hardlink and symlink modes make the exported files share the storage with the cache,
+// so the exported files must not be modified, and may disappear on the cache eviction (symlink).
+func WithLinkMode(mode filelink.Mode) ExportOption {
+	return func(o *exportOpts) {
+		o.linkMode = mode
+	}
+}
+
+// WithLinkReporter sets the function to be called with the link mode that was actually used for each exported file.
+// The used mode may differ from WithLinkMode, as the link mode falls back on a failure.
+func WithLinkReporter(fn func(sha256sum string, used filelink.Mode)) ExportOption {
+	return func(o *exportOpts) {
+		o.linkReporter = fn
 	}
-	if err := c.writeMetadataFiles(sha256sum, u, m); err != nil {
+}
+
+// link links the blob to dst with opts.linkMode.
+func (opts *exportOpts) link(dst, blob, sha256sum string) error {
+	used, err := filelink.Link(dst, blob, opts.linkMode)
+	if err != nil {
 		return err
 	}
+	if opts.linkReporter != nil {
+		opts.linkReporter(sha256sum, used)
+	}
 	return nil
 }
 
-func (c *Cache) Export(dir string) (map[string]string, error) {
-	blobs, err := os.ReadDir(filepath.Join(c.dir, BlobsSHA256RelPath)) // no need to use securejoin (const)
+// Export exports the cached blobs in all the layers to dir with their original basenames, and returns map[basename]sha256sum .
+func (c *Cache) Export(dir string, options ...ExportOption) (map[string]string, error) {
+	opts := newExportOpts(options)
+	if _, err := filelink.ParseMode(string(opts.linkMode)); err != nil {
+		return nil, err
+	}
+	blobs, err := c.layerBlobs()
 	if err != nil {
 		return nil, err
 	}
@@ -216,18 +475,7 @@ func (c *Cache) Export(dir string) (map[string]string, error) {
 	}
 	exported := make(map[string]string)
 	for _, f := range blobs {
-		if f.IsDir() {
-			continue
-		}
-		sha256sum := f.Name()
-		if strings.HasPrefix(sha256sum, ".") || strings.HasSuffix(sha256sum, ".tmp") {
-			continue
-		}
-		if err = digest.SHA256.Validate(sha256sum); err != nil {
-			logrus.WithError(err).Errorf("Invalid sha256sum %q", sha256sum)
-			continue
-		}
-		cpSrc := filepath.Join(c.dir, BlobsSHA256RelPath, sha256sum) // no need to use securejoin (sha256sum is verified)
+		sha256sum := f.sha256sum
 		basename := "UNKNOWN-" + sha256sum
 		if m, err := c.MetadataBySHA256(sha256sum); err == nil && m.Basename != "" {
 			basename = filepath.Base(m.Basename)
@@ -242,7 +490,7 @@ func (c *Cache) Export(dir string) (map[string]string, error) {
 			logrus.Errorf("Avoiding to overwrite existing file %q", cpDst)
 			continue
 		}
-		if err = fs.CopyFile(cpDst, cpSrc); err != nil {
+		if err = opts.link(cpDst, f.path, sha256sum); err != nil {
 			return exported, err
 		}
 		exported[basename] = sha256sum
@@ -332,6 +580,17 @@ func (c *Cache) importFile(nameFull string) (sha256sum string, err error) {
 // ImportWithReader imports from the reader.
 // Does not create the metadata files.
 func (c *Cache) ImportWithReader(r io.Reader) (sha256sum string, err error) {
+	return c.importWithReader(r, "")
+}
+
+// importWithReader imports from the reader.
+// If expected is non-empty, the blob is not imported unless the sha256sum matches.
+func (c *Cache) importWithReader(r io.Reader, expected string) (sha256sum string, err error) {
+	unlockCache, err := c.lockCache(false)
+	if err != nil {
+		return "", err
+	}
+	defer unlockCache()
 	blobsSHA256Dir := filepath.Join(c.dir, BlobsSHA256RelPath) // no need to use securejoin (const)
 	tmpW, err := os.CreateTemp(blobsSHA256Dir, ".import-*.tmp")
 	if err != nil {
@@ -344,11 +603,15 @@ func (c *Cache) ImportWithReader(r io.Reader) (sha256sum string, err error) {
 	digester := digest.SHA256.Digester()
 	hasher := digester.Hash()
 	mw := io.MultiWriter(tmpW, hasher)
-	if _, err = io.Copy(mw, r); err != nil {
+	written, err := io.Copy(mw, r)
+	if err != nil {
 		return "", err
 	}
 	sha256sum = digester.Digest().Encoded()
-	blob, err := c.BlobAbsPath(sha256sum)
+	if expected != "" && sha256sum != expected {
+		return "", fmt.Errorf("expected sha256sum %q, got %q", expected, sha256sum)
+	}
+	blob, err := c.writableBlobAbsPath(sha256sum)
 	if err != nil {
 		return "", err
 	}
@@ -361,6 +624,7 @@ func (c *Cache) ImportWithReader(r io.Reader) (sha256sum string, err error) {
 	if err = os.Rename(tmpW.Name(), blob); err != nil {
 		return "", err
 	}
+	c.blobAdded(sha256sum, written)
 	return sha256sum, nil
 }
 
@@ -377,74 +641,152 @@ func (c *Cache) ImportWithURL(u *url.URL, m *Metadata) (sha256sum string, err er
 	if err != nil {
 		return "", err
 	}
-	err = c.writeMetadataFiles(sha256sum, u, m)
+	unlock, err := c.lockBlob(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	err = c.writeMetadataFiles(sha256sum, u, m, "")
 	return sha256sum, err
 }
 
-// writeMetadataFiles writes metadata files and reverse URL files.
-// Note that a URL is not unique.
-// Existing files are overwritten.
-func (c *Cache) writeMetadataFiles(sha256sum string, u *url.URL, m *Metadata) error {
-	if m != nil {
-		j, err := json.Marshal(m)
-		if err != nil {
-			return err
-		}
-		metadataFileAbs, err := c.MetadataFileAbsPath(sha256sum)
-		if err != nil {
-			return err
-		}
-		if err = os.WriteFile(metadataFileAbs, j, 0644); err != nil {
-			return fmt.Errorf("failed to create %q: %w", metadataFileAbs, err)
+// MergeMetadata merges the basename of m, the origin URL u (optional), and the provider (optional)
+// into the metadata of the cached blob, e.g., to record another basename on a cache hit.
+// The metadata of the blobs in the read-only layers is not updated, as the read-only layers are never written.
+func (c *Cache) MergeMetadata(sha256sum string, u *url.URL, m *Metadata, provider string) error {
+	if err := ValidateMetadata(m); err != nil {
+		return err
+	}
+	blob, err := c.writableBlobAbsPath(sha256sum) // also verifies sha256sum string representation
+	if err != nil {
+		return err
+	}
+	unlockCache, err := c.lockCache(false)
+	if err != nil {
+		return err
+	}
+	defer unlockCache()
+	unlock, err := c.lockBlob(sha256sum)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := os.Stat(blob); err != nil {
+		if errors.Is(err, os.ErrNotExist) && c.readOnlyBlobAbsPath(sha256sum) != "" {
+			return nil
 		}
+		return err
+	}
+	return c.writeMetadataFiles(sha256sum, u, m, provider)
+}
+
+// writeMetadataFiles writes the metadata file and the reverse URL file.
+// The metadata file is merged with the existing one (see Metadata.merge).
+// Note that a URL is not unique.
+// Existing files are overwritten atomically, so that the readers never see half-written files.
+// The caller must hold the blob lock.
+func (c *Cache) writeMetadataFiles(sha256sum string, u *url.URL, m *Metadata, provider string) error {
+	if err := c.writeMetadataFile(sha256sum, u, m, provider); err != nil {
+		return err
 	}
 	if u != nil && u.Scheme != "oci" && !strings.HasPrefix(u.Scheme, "oci+") {
 		revURLFileAbs, err := c.ReverseURLFileAbsPath(u)
 		if err != nil {
 			return err
 		}
-		if err = os.WriteFile(revURLFileAbs, []byte("sha256:"+sha256sum), 0644); err != nil {
+		if existing, err := readReverseURLFile(revURLFileAbs); err == nil && existing == sha256sum {
+			return nil
+		}
+		// The file must not contain anything but the digest, as older versions parse the whole file as a digest.
+		// The origin URL is recorded in the metadata.
+		if err = writeFileAtomic(revURLFileAbs, []byte("sha256:"+sha256sum), 0644); err != nil {
 			return fmt.Errorf("failed to create %q: %w", revURLFileAbs, err)
 		}
 	}
 	return nil
 }
 
+// writeFileAtomic writes the file via a temporary file in the same directory.
+// The temporary file name starts with "." and ends with ".tmp".
+func writeFileAtomic(file string, b []byte, perm os.FileMode) error {
+	tmpW, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmpW.Close()
+		os.Remove(tmpW.Name())
+	}()
+	if _, err = tmpW.Write(b); err != nil {
+		return err
+	}
+	if err = tmpW.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmpW.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpW.Name(), file)
+}
+
 // MetadataBySHA256 returns the metadata for the blob.
+// The writable layer is looked up first, and then the read-only layers in order.
+// The legacy metadata format (version 0) is converted to the current format.
 // Not always available.
 func (c *Cache) MetadataBySHA256(sha256sum string) (*Metadata, error) {
-	metadataFileAbs, err := c.MetadataFileAbsPath(sha256sum)
+	rel, err := c.MetadataFileRelPath(sha256sum)
 	if err != nil {
 		return nil, err
 	}
-	b, err := os.ReadFile(metadataFileAbs)
-	if err != nil {
-		return nil, err
+	var b []byte
+	for _, dir := range c.layers() {
+		b, err = os.ReadFile(filepath.Join(dir, rel)) // no need to use securejoin (rel is verified)
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			break
+		}
 	}
-	var m Metadata
-	if err = json.Unmarshal(b, &m); err != nil {
+	if err != nil {
 		return nil, err
 	}
-	return &m, nil
+	return ParseMetadata(b)
 }
 
 // SHA256ByOriginURL returns the sha256sum by the origin URL.
+// The writable layer is looked up first, and then the read-only layers in order.
 // Not always available.
 // Do not use this unless you are sure that the URL is unique.
 func (c *Cache) SHA256ByOriginURL(u *url.URL) (string, error) {
 	if u.Scheme == "oci" || strings.HasPrefix(u.Scheme, "oci+") {
 		return "", fmt.Errorf("oci URL scheme is not supported")
 	}
-	revUrlFileAbs, err := c.ReverseURLFileAbsPath(u)
+	rel, err := c.ReverseURLFileRelPath(u)
 	if err != nil {
 		return "", err
 	}
-	b, err := os.ReadFile(revUrlFileAbs)
+	var sha256sum string
+	for _, dir := range c.layers() {
+		sha256sum, err = readReverseURLFile(filepath.Join(dir, rel)) // no need to use securejoin (rel is verified)
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	return sha256sum, err
+}
+
+// layers returns the writable layer and the read-only layers.
+func (c *Cache) layers() []string {
+	return append([]string{c.dir}, c.roDirs...)
+}
+
+// readReverseURLFile reads a file in ReverseURLRelPath, and returns the sha256sum.
+// Only the first line is parsed, as the files created by some development versions had the URL line.
+func readReverseURLFile(revURLFileAbs string) (string, error) {
+	b, err := os.ReadFile(revURLFileAbs)
 	if err != nil {
 		return "", err
 	}
-	s := strings.TrimSpace(string(b))
-	d, err := digest.Parse(s)
+	s, _, _ := strings.Cut(strings.TrimSpace(string(b)), "\n")
+	d, err := digest.Parse(strings.TrimSpace(s))
 	if err != nil {
 		return "", err
 	}
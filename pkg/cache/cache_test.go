@@ -3,6 +3,7 @@ package cache
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,8 +15,10 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/filelink"
 	"gotest.tools/v3/assert"
 )
 
@@ -237,6 +240,27 @@ func TestCacheExportImport(t *testing.T) {
 		assert.DeepEqual(t, blobsBySHA256[mapByBasename[basename]].b, b)
 	}
 
+	t.Run("Hardlink", func(t *testing.T) {
+		hardlinkDir := t.TempDir()
+		used := make(map[string]filelink.Mode)
+		exported, err := cache.Export(hardlinkDir, WithLinkMode(filelink.ModeHardlink),
+			WithLinkReporter(func(sha256sum string, mode filelink.Mode) {
+				used[sha256sum] = mode
+			}))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, mapByBasename, exported)
+		for basename, sha256sum := range exported {
+			assert.Equal(t, filelink.ModeHardlink, used[sha256sum])
+			exportedSt, err := os.Stat(filepath.Join(hardlinkDir, basename))
+			assert.NilError(t, err)
+			blob, err := cache.BlobAbsPath(sha256sum)
+			assert.NilError(t, err)
+			blobSt, err := os.Stat(blob)
+			assert.NilError(t, err)
+			assert.Check(t, os.SameFile(blobSt, exportedSt))
+		}
+	})
+
 	t.Run("ImportByDir", func(t *testing.T) {
 		cache2Dir := t.TempDir()
 		cache2, err := New(cache2Dir)
@@ -264,3 +288,83 @@ func TestCacheExportImport(t *testing.T) {
 		}
 	})
 }
+
+func TestCacheEnsureResume(t *testing.T) {
+	blob := newTestBlob("resumable")
+	var (
+		lastRange string
+		requests  int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		lastRange = r.Header.Get("Range")
+		// http.ServeContent supports Range requests
+		http.ServeContent(w, r, blob.basename, time.Time{}, bytes.NewReader(blob.b))
+	}))
+	defer srv.Close()
+	noRangeSrv := newTestHTTPServer(t, map[string]*testBlob{blob.sha256: blob})
+	defer noRangeSrv.Close()
+
+	ctx := context.TODO()
+	m := &Metadata{
+		Basename: blob.basename,
+	}
+	u, err := url.Parse(srv.URL + "/" + blob.basename)
+	assert.NilError(t, err)
+	half := len(blob.b) / 2
+
+	writePartial := func(t testing.TB, cache *Cache, b []byte) string {
+		t.Helper()
+		partial := filepath.Join(cache.Dir(), BlobsSHA256RelPath, ".download-"+blob.sha256+".tmp")
+		assert.NilError(t, os.WriteFile(partial, b, 0644))
+		return partial
+	}
+
+	t.Run("Resume", func(t *testing.T) {
+		cache, err := New(t.TempDir())
+		assert.NilError(t, err)
+		partial := writePartial(t, cache, blob.b[:half])
+		assert.NilError(t, cache.Ensure(ctx, u, blob.sha256, m))
+		assert.Equal(t, fmt.Sprintf("bytes=%d-", half), lastRange)
+		testCacheDir(t, cache, map[string]*testBlob{blob.sha256: blob})
+		_, err = os.Stat(partial)
+		assert.Check(t, errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("ResumeUnsupported", func(t *testing.T) {
+		cache, err := New(t.TempDir())
+		assert.NilError(t, err)
+		writePartial(t, cache, blob.b[:half])
+		assert.NilError(t, cache.Ensure(ctx, noRangeSrv.basenameURL(blob), blob.sha256, m))
+		testCacheDir(t, cache, map[string]*testBlob{blob.sha256: blob})
+	})
+
+	t.Run("BrokenPartial", func(t *testing.T) {
+		cache, err := New(t.TempDir())
+		assert.NilError(t, err)
+		partial := writePartial(t, cache, []byte("broken"))
+		// The download is restarted from the beginning, without failing over to another provider
+		requests = 0
+		assert.NilError(t, cache.Ensure(ctx, u, blob.sha256, m))
+		assert.Equal(t, 2, requests)
+		assert.Equal(t, "", lastRange)
+		_, err = os.Stat(partial)
+		assert.Check(t, errors.Is(err, os.ErrNotExist))
+		testCacheDir(t, cache, map[string]*testBlob{blob.sha256: blob})
+	})
+
+	t.Run("BrokenUpstream", func(t *testing.T) {
+		cache, err := New(t.TempDir())
+		assert.NilError(t, err)
+		partial := writePartial(t, cache, blob.b[:half])
+		brokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, blob.basename, time.Time{}, bytes.NewReader(bytes.ToUpper(blob.b)))
+		}))
+		defer brokenSrv.Close()
+		brokenU, err := url.Parse(brokenSrv.URL + "/" + blob.basename)
+		assert.NilError(t, err)
+		assert.ErrorContains(t, cache.Ensure(ctx, brokenU, blob.sha256, m), "expected sha256sum")
+		_, err = os.Stat(partial)
+		assert.Check(t, errors.Is(err, os.ErrNotExist), "broken partial file must be removed")
+	})
+}
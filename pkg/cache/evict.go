@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// WithMaxSize limits the total size of the blobs.
+// When the cache grows past the limit, the least recently used blobs are evicted.
+// Pinned blobs are never evicted.
+// Zero means unlimited.
+func WithMaxSize(maxSize int64) Option {
+	return func(c *Cache) {
+		c.maxSize = maxSize
+	}
+}
+
+// Pin protects the blobs from eviction, during the lifetime of the Cache object.
+func (c *Cache) Pin(sha256sums ...string) {
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+	for _, sha256sum := range sha256sums {
+		c.pinned[sha256sum] = struct{}{}
+	}
+}
+
+// Touch records the access time of the blob for the LRU eviction.
+// The access time is recorded as the modification time of the blob,
+// as the actual access time is often not updated (noatime, relatime).
+// Blobs in the read-only layers are not touched.
+func (c *Cache) Touch(sha256sum string) error {
+	blob, err := c.writableBlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = os.Chtimes(blob, now, now)
+	if errors.Is(err, os.ErrNotExist) && c.readOnlyBlobAbsPath(sha256sum) != "" {
+		return nil
+	}
+	return err
+}
+
+// touchAndPin calls Touch and Pin. Failures of Touch are just logged.
+func (c *Cache) touchAndPin(sha256sum string) {
+	if err := c.Touch(sha256sum); err != nil {
+		logrus.WithError(err).Debugf("Failed to record the access time of %s", sha256sum)
+	}
+	c.Pin(sha256sum)
+}
+
+// blobAdded is called when a blob is added to the cache.
+// The caller must hold the shared cache lock.
+// Failures of eviction are just logged.
+func (c *Cache) blobAdded(sha256sum string, size int64) {
+	c.Pin(sha256sum)
+	if c.maxSize <= 0 {
+		return
+	}
+	c.evictMu.Lock()
+	if c.size >= 0 {
+		c.size += size
+	}
+	needsEviction := c.size < 0 || c.size > c.maxSize
+	c.evictMu.Unlock()
+	if needsEviction {
+		// Not Evict, as taking the shared cache lock again deadlocks when an exclusive locker (GC) is waiting
+		if _, err := c.evictLocked(c.maxSize); err != nil {
+			logrus.WithError(err).Warn("Failed to evict blobs")
+		}
+	}
+}
+
+type blobInfo struct {
+	sha256sum string
+	size      int64
+	modTime   time.Time
+}
+
+// Evict removes the least recently used blobs that are not pinned, along with their metadata files
+// and reverse URL files, until the total size of the blobs gets smaller than or equal to maxSize.
+func (c *Cache) Evict(maxSize int64) (*GCResult, error) {
+	unlockCache, err := c.lockCache(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockCache()
+	return c.evictLocked(maxSize)
+}
+
+// evictLocked is similar to Evict, but the caller must hold the shared cache lock.
+func (c *Cache) evictLocked(maxSize int64) (*GCResult, error) {
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+	blobsDir := filepath.Join(c.dir, BlobsSHA256RelPath) // no need to use securejoin (const)
+	ents, err := os.ReadDir(blobsDir)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		total      int64
+		candidates []blobInfo
+	)
+	for _, ent := range ents {
+		sha256sum := ent.Name()
+		if ent.IsDir() || digest.SHA256.Validate(sha256sum) != nil {
+			continue
+		}
+		st, err := ent.Info()
+		if err != nil {
+			return nil, err
+		}
+		total += st.Size()
+		if _, ok := c.pinned[sha256sum]; ok {
+			continue
+		}
+		candidates = append(candidates, blobInfo{sha256sum: sha256sum, size: st.Size(), modTime: st.ModTime()})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].modTime.Equal(candidates[j].modTime) {
+			return candidates[i].modTime.Before(candidates[j].modTime)
+		}
+		return candidates[i].sha256sum < candidates[j].sha256sum
+	})
+	victims := make(map[string]struct{})
+	for _, blob := range candidates {
+		if total <= maxSize {
+			break
+		}
+		unlock, err := c.tryLockBlob(blob.sha256sum)
+		if err != nil {
+			// Being used by another process
+			logrus.WithError(err).Debugf("Skipping evicting %s", blob.sha256sum)
+			continue
+		}
+		defer unlock()
+		logrus.Debugf("Evicting %s (%d bytes, last accessed at %s)", blob.sha256sum, blob.size, blob.modTime.Format(time.RFC3339))
+		victims[blob.sha256sum] = struct{}{}
+		total -= blob.size
+	}
+	if total > maxSize {
+		logrus.Warnf("The cache size (%d bytes) exceeds the limit (%d bytes), but the remaining blobs are in use", total, maxSize)
+	}
+	c.size = total
+	if len(victims) == 0 {
+		return &GCResult{}, nil
+	}
+	return c.prune(func(sha256sum string) bool {
+		_, ok := victims[sha256sum]
+		return ok
+	}, false)
+}
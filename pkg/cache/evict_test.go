@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheEvict(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar", "baz")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	blobs := make(map[string]*testBlob)
+	for _, blob := range blobsBySHA256 {
+		blobs[blob.basename] = blob
+	}
+	foo, bar, baz := blobs["foo"], blobs["bar"], blobs["baz"]
+	blobSize := int64(len(foo.b)) // all the blobs have the same size
+
+	dir := t.TempDir()
+	ensure := func(c *Cache, blob *testBlob) {
+		m := &Metadata{Basename: blob.basename}
+		assert.NilError(t, c.Ensure(context.TODO(), testServer.basenameURL(blob), blob.sha256, m))
+	}
+	cached := func(c *Cache, blob *testBlob) bool {
+		ok, err := c.Cached(blob.sha256)
+		assert.NilError(t, err)
+		return ok
+	}
+	setAccessTime := func(c *Cache, blob *testBlob, tm time.Time) {
+		f, err := c.BlobAbsPath(blob.sha256)
+		assert.NilError(t, err)
+		assert.NilError(t, os.Chtimes(f, tm, tm))
+	}
+
+	cache, err := New(dir)
+	assert.NilError(t, err)
+	for _, blob := range []*testBlob{foo, bar, baz} {
+		ensure(cache, blob)
+	}
+	now := time.Now()
+	setAccessTime(cache, foo, now.Add(-3*time.Hour))
+	setAccessTime(cache, bar, now.Add(-2*time.Hour))
+	setAccessTime(cache, baz, now.Add(-1*time.Hour))
+
+	// foo is the least recently used one, but pinned
+	cache, err = New(dir)
+	assert.NilError(t, err)
+	cache.Pin(foo.sha256)
+	res, err := cache.Evict(2 * blobSize)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{bar.sha256}, res.RemovedBlobs)
+	assert.Check(t, cached(cache, foo))
+	assert.Check(t, !cached(cache, bar))
+	assert.Check(t, cached(cache, baz))
+	_, err = cache.MetadataBySHA256(bar.sha256)
+	assert.Check(t, errors.Is(err, os.ErrNotExist), err)
+	_, err = cache.SHA256ByOriginURL(testServer.basenameURL(bar))
+	assert.Check(t, errors.Is(err, os.ErrNotExist), err)
+
+	// Downloading bar evicts baz, as foo was accessed more recently
+	cache, err = New(dir, WithMaxSize(2*blobSize))
+	assert.NilError(t, err)
+	assert.NilError(t, cache.Touch(foo.sha256))
+	ensure(cache, bar)
+	assert.Check(t, cached(cache, foo))
+	assert.Check(t, cached(cache, bar))
+	assert.Check(t, !cached(cache, baz))
+
+	// Pinned blobs are never evicted, even if the cache size exceeds the limit
+	cache.Pin(foo.sha256)
+	res, err = cache.Evict(0)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(res.RemovedBlobs))
+	assert.Check(t, cached(cache, foo))
+	assert.Check(t, cached(cache, bar))
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+type GCOpts struct {
+	DryRun bool // Only compute GCResult, without removing anything
+}
+
+type GCResult struct {
+	RemovedBlobs []string // sha256sums of the removed blobs
+	FreedBytes   int64    // Including the metadata files, the reverse URL files, and the partial blobs
+}
+
+// GC removes the blobs that are not referenced by keep (sha256sums),
+// along with their metadata files, reverse URL files, and partially downloaded blobs.
+//
+// GC waits for the other processes to finish adding blobs.
+func (c *Cache) GC(keep []string, opts GCOpts) (*GCResult, error) {
+	keepSet := make(map[string]struct{}, len(keep))
+	for _, sha256sum := range keep {
+		keepSet[sha256sum] = struct{}{}
+	}
+	unlockCache, err := c.lockCache(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockCache()
+	res, err := c.prune(func(sha256sum string) bool {
+		_, ok := keepSet[sha256sum]
+		return !ok
+	}, opts.DryRun)
+	if err != nil || opts.DryRun {
+		return res, err
+	}
+	// The lock files can be removed safely, as nobody can wait for the blob locks
+	// without holding the shared cache lock
+	for _, sha256sum := range res.RemovedBlobs {
+		if err = os.Remove(c.blobLockFile(sha256sum)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// prune removes the blobs that satisfy the shouldRemove function,
+// along with their metadata files, reverse URL files, and partially downloaded blobs.
+func (c *Cache) prune(shouldRemove func(sha256sum string) bool, dryRun bool) (*GCResult, error) {
+	kept := func(sha256sum string) bool {
+		return !shouldRemove(sha256sum)
+	}
+	var res GCResult
+	remove := func(f string, size int64) error {
+		if !dryRun {
+			logrus.Debugf("Removing %q", f)
+			if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		res.FreedBytes += size
+		return nil
+	}
+
+	// Blobs
+	blobsDir := filepath.Join(c.dir, BlobsSHA256RelPath) // no need to use securejoin (const)
+	blobs, err := os.ReadDir(blobsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range blobs {
+		if f.IsDir() {
+			continue
+		}
+		name := f.Name()
+		sha256sum := name
+		isPartial := strings.HasPrefix(name, ".download-") && strings.HasSuffix(name, ".tmp")
+		if isPartial {
+			sha256sum = strings.TrimSuffix(strings.TrimPrefix(name, ".download-"), ".tmp")
+		}
+		if err = digest.SHA256.Validate(sha256sum); err != nil {
+			// Other tmp files may be still being written
+			continue
+		}
+		if kept(sha256sum) {
+			continue
+		}
+		st, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		if err = remove(filepath.Join(blobsDir, name), st.Size()); err != nil { // no need to use securejoin (name is verified)
+			return nil, err
+		}
+		if !isPartial {
+			res.RemovedBlobs = append(res.RemovedBlobs, sha256sum)
+		}
+	}
+
+	// Metadata files
+	metadataDir := filepath.Join(c.dir, MetadataSHA256RelPath) // no need to use securejoin (const)
+	metadataFiles, err := os.ReadDir(metadataDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range metadataFiles {
+		sha256sum := f.Name()
+		if f.IsDir() || digest.SHA256.Validate(sha256sum) != nil || kept(sha256sum) {
+			continue
+		}
+		st, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		if err = remove(filepath.Join(metadataDir, sha256sum), st.Size()); err != nil { // no need to use securejoin (sha256sum is verified)
+			return nil, err
+		}
+	}
+
+	// Reverse URL files
+	revURLDir := filepath.Join(c.dir, ReverseURLRelPath) // no need to use securejoin (const)
+	revURLFiles, err := os.ReadDir(revURLDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range revURLFiles {
+		sha256OfURL := f.Name()
+		if f.IsDir() || digest.SHA256.Validate(sha256OfURL) != nil {
+			continue
+		}
+		revURLFile := filepath.Join(revURLDir, sha256OfURL) // no need to use securejoin (sha256OfURL is verified)
+		sha256sum, err := readReverseURLFile(revURLFile)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to read %q, removing", revURLFile)
+		} else if kept(sha256sum) {
+			continue
+		}
+		st, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		if err = remove(revURLFile, st.Size()); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+// Clean removes all the files in the cache.
+// Clean waits for the other processes to finish adding blobs.
+func (c *Cache) Clean() error {
+	unlockCache, err := c.lockCache(true)
+	if err != nil {
+		return err
+	}
+	defer unlockCache()
+	ents, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	locksDir := filepath.Dir(CacheLockRelPath)
+	for _, ent := range ents {
+		if ent.Name() == locksDir {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(c.dir, ent.Name())); err != nil { // no need to use securejoin (ent.Name() is a direct child)
+			return err
+		}
+	}
+	// The blob lock files can be removed, as in GC
+	if err = os.RemoveAll(filepath.Join(c.dir, BlobLocksSHA256RelPath)); err != nil { // no need to use securejoin (const)
+		return err
+	}
+	return c.mkdirAll()
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheGC(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar", "baz")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	var foo, bar, baz *testBlob
+	for _, blob := range blobsBySHA256 {
+		m := &Metadata{Basename: blob.basename}
+		assert.NilError(t, cache.Ensure(context.TODO(), testServer.basenameURL(blob), blob.sha256, m))
+		switch blob.basename {
+		case "foo":
+			foo = blob
+		case "bar":
+			bar = blob
+		case "baz":
+			baz = blob
+		}
+	}
+	// A stale partial blob of baz
+	partial := filepath.Join(cache.Dir(), BlobsSHA256RelPath, ".download-"+baz.sha256+".tmp")
+	assert.NilError(t, os.WriteFile(partial, baz.b[:1], 0644))
+
+	res, err := cache.GC([]string{foo.sha256}, GCOpts{DryRun: true})
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(res.RemovedBlobs))
+	assert.Check(t, res.FreedBytes > int64(len(bar.b)+len(baz.b)+1))
+	for _, blob := range blobsBySHA256 {
+		cached, err := cache.Cached(blob.sha256)
+		assert.NilError(t, err)
+		assert.Check(t, cached, "dry-run must not remove anything")
+	}
+
+	res2, err := cache.GC([]string{foo.sha256}, GCOpts{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, res, res2)
+
+	_, err = os.Stat(partial)
+	assert.Check(t, errors.Is(err, os.ErrNotExist))
+	for _, blob := range blobsBySHA256 {
+		cached, err := cache.Cached(blob.sha256)
+		assert.NilError(t, err)
+		_, mErr := cache.MetadataBySHA256(blob.sha256)
+		_, revErr := cache.SHA256ByOriginURL(testServer.basenameURL(blob))
+		if blob == foo {
+			assert.Check(t, cached)
+			assert.NilError(t, mErr)
+			assert.NilError(t, revErr)
+		} else {
+			assert.Check(t, !cached)
+			assert.Check(t, errors.Is(mErr, os.ErrNotExist), mErr)
+			assert.Check(t, errors.Is(revErr, os.ErrNotExist), revErr)
+		}
+	}
+
+	res, err = cache.GC([]string{foo.sha256}, GCOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(res.RemovedBlobs))
+	assert.Equal(t, int64(0), res.FreedBytes)
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheReadOnlyLayers(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	var foo, bar *testBlob
+	for _, blob := range blobsBySHA256 {
+		if blob.basename == "foo" {
+			foo = blob
+		} else {
+			bar = blob
+		}
+	}
+
+	roDir := t.TempDir()
+	roCache, err := New(roDir)
+	assert.NilError(t, err)
+	assert.NilError(t, roCache.Ensure(context.TODO(), testServer.basenameURL(foo), foo.sha256, &Metadata{Basename: foo.basename}))
+	assert.NilError(t, os.Chmod(filepath.Join(roDir, BlobsSHA256RelPath), 0555))
+	defer os.Chmod(filepath.Join(roDir, BlobsSHA256RelPath), 0755)
+
+	_, err = New(t.TempDir(), WithReadOnlyLayers(filepath.Join(roDir, "nonexistent")))
+	assert.ErrorContains(t, err, "invalid read-only layer")
+
+	rwDir := t.TempDir()
+	cache, err := New(rwDir, WithReadOnlyLayers(roDir))
+	assert.NilError(t, err)
+
+	cached, err := cache.Cached(foo.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, cached)
+	blob, err := cache.BlobAbsPath(foo.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, strings.HasPrefix(blob, roDir), blob)
+	m, err := cache.MetadataBySHA256(foo.sha256)
+	assert.NilError(t, err)
+	assert.Equal(t, foo.basename, m.Basename)
+	sha256sum, err := cache.SHA256ByOriginURL(testServer.basenameURL(foo))
+	assert.NilError(t, err)
+	assert.Equal(t, foo.sha256, sha256sum)
+	assert.NilError(t, cache.Touch(foo.sha256))
+
+	// Cache hit in the read-only layer does not write anything to the writable layer
+	assert.NilError(t, cache.Ensure(context.TODO(), testServer.basenameURL(foo), foo.sha256, &Metadata{Basename: foo.basename}))
+	_, err = os.Stat(filepath.Join(rwDir, BlobsSHA256RelPath, foo.sha256))
+	assert.Check(t, os.IsNotExist(err))
+
+	// New blobs go to the writable layer
+	assert.NilError(t, cache.Ensure(context.TODO(), testServer.basenameURL(bar), bar.sha256, &Metadata{Basename: bar.basename}))
+	blob, err = cache.BlobAbsPath(bar.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, strings.HasPrefix(blob, rwDir), blob)
+	_, err = os.Stat(filepath.Join(roDir, BlobsSHA256RelPath, bar.sha256))
+	assert.Check(t, os.IsNotExist(err))
+
+	// A corrupted blob in the read-only layer is ignored after Quarantine, and downloaded again into the writable layer
+	roBlob := filepath.Join(roDir, BlobsSHA256RelPath, foo.sha256)
+	assert.NilError(t, os.Chmod(roBlob, 0644))
+	assert.NilError(t, os.WriteFile(roBlob, []byte("corrupted"), 0644))
+	assert.ErrorIs(t, cache.VerifyBlob(foo.sha256), ErrCorruptedBlob)
+	assert.NilError(t, cache.Quarantine(foo.sha256))
+	cached, err = cache.Cached(foo.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, !cached)
+	assert.NilError(t, cache.Ensure(context.TODO(), testServer.basenameURL(foo), foo.sha256, &Metadata{Basename: foo.basename}))
+	blob, err = cache.BlobAbsPath(foo.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, strings.HasPrefix(blob, rwDir), blob)
+	assert.NilError(t, cache.VerifyBlob(foo.sha256))
+}
+
+func TestCacheReadOnlyLayersListAndExport(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar", "baz")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	blobsByBasename := make(map[string]*testBlob)
+	for _, blob := range blobsBySHA256 {
+		blobsByBasename[blob.basename] = blob
+	}
+	ensure := func(c *Cache, basename string) {
+		blob := blobsByBasename[basename]
+		assert.NilError(t, c.Ensure(context.TODO(), testServer.basenameURL(blob), blob.sha256, &Metadata{Basename: blob.basename}))
+	}
+
+	roDir := t.TempDir()
+	roCache, err := New(roDir)
+	assert.NilError(t, err)
+	ensure(roCache, "foo")
+	ensure(roCache, "bar")
+
+	rwDir := t.TempDir()
+	cache, err := New(rwDir, WithReadOnlyLayers(roDir))
+	assert.NilError(t, err)
+	// bar exists in both the layers
+	_, err = cache.ImportWithReader(bytes.NewReader(blobsByBasename["bar"].b))
+	assert.NilError(t, err)
+	ensure(cache, "baz")
+
+	expected := make(map[string]string) // key: basename, value: sha256sum
+	for basename, blob := range blobsByBasename {
+		expected[basename] = blob.sha256
+	}
+
+	ents, err := cache.List()
+	assert.NilError(t, err)
+	assert.Equal(t, len(blobsBySHA256), len(ents))
+	for i, ent := range ents {
+		if i > 0 {
+			assert.Check(t, ents[i-1].SHA256 < ent.SHA256)
+		}
+		assert.Check(t, blobsBySHA256[ent.SHA256] != nil, ent.SHA256)
+	}
+
+	byBasenames, err := cache.SHA256sByBasenames()
+	assert.NilError(t, err)
+	for basename, sha256sum := range expected {
+		assert.DeepEqual(t, []string{sha256sum}, byBasenames[basename])
+	}
+
+	exportDir := t.TempDir()
+	exported, err := cache.Export(exportDir)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expected, exported)
+	for basename, blob := range blobsByBasename {
+		b, err := os.ReadFile(filepath.Join(exportDir, basename))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, blob.b, b)
+	}
+
+	var tarBuf bytes.Buffer
+	files, err := cache.ExportTar(&tarBuf)
+	assert.NilError(t, err)
+	assert.Equal(t, len(blobsBySHA256), len(files))
+	imported, err := New(t.TempDir())
+	assert.NilError(t, err)
+	_, err = imported.ImportTar(&tarBuf)
+	assert.NilError(t, err)
+	for sha256sum := range blobsBySHA256 {
+		cached, err := imported.Cached(sha256sum)
+		assert.NilError(t, err)
+		assert.Check(t, cached, sha256sum)
+	}
+
+	files, err = cache.ExportOCILayout(t.TempDir(), "")
+	assert.NilError(t, err)
+	assert.Equal(t, len(blobsBySHA256), len(files))
+	for _, f := range files {
+		assert.Equal(t, blobsBySHA256[f.SHA256].basename, f.Basename)
+	}
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+// Entry describes a cached blob.
+type Entry struct {
+	SHA256   string             `json:"SHA256"`
+	Size     int64              `json:"Size"`
+	LastUsed time.Time          `json:"LastUsed"`           // Used for the LRU eviction
+	Metadata *Metadata          `json:"Metadata,omitempty"` // Not always available
+	URLs     []string           `json:"URLs,omitempty"`     // Origin URLs recorded in the metadata (redacted)
+	FileSpec *filespec.FileSpec `json:"FileSpec,omitempty"` // Parsed from Metadata.Basename, with the package identity
+}
+
+// layerBlob is a blob in a layer.
+type layerBlob struct {
+	sha256sum string
+	path      string // absolute path in the layer
+	info      os.FileInfo
+}
+
+// layerBlobs lists the blobs in the writable layer and the read-only layers, sorted by the sha256sum.
+// A blob that exists in multiple layers is listed once, with the path in the first layer (see BlobAbsPath).
+// The blobs in the read-only layers that are masked by Quarantine are omitted.
+func (c *Cache) layerBlobs() ([]layerBlob, error) {
+	seen := make(map[string]struct{})
+	var res []layerBlob
+	for i, dir := range c.layers() {
+		blobsDir := filepath.Join(dir, BlobsSHA256RelPath) // no need to use securejoin (const)
+		ents, err := os.ReadDir(blobsDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, ent := range ents {
+			sha256sum := ent.Name()
+			if ent.IsDir() || digest.SHA256.Validate(sha256sum) != nil {
+				continue
+			}
+			if _, ok := seen[sha256sum]; ok {
+				continue
+			}
+			if i > 0 {
+				c.roMu.Lock()
+				_, masked := c.roMasked[sha256sum]
+				c.roMu.Unlock()
+				if masked {
+					continue
+				}
+			}
+			st, err := ent.Info()
+			if err != nil {
+				return nil, err
+			}
+			seen[sha256sum] = struct{}{}
+			res = append(res, layerBlob{
+				sha256sum: sha256sum,
+				path:      filepath.Join(blobsDir, sha256sum), // no need to use securejoin (sha256sum is verified)
+				info:      st,
+			})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].sha256sum < res[j].sha256sum })
+	return res, nil
+}
+
+// List lists the cached blobs in all the layers, sorted by the sha256sum.
+func (c *Cache) List() ([]Entry, error) {
+	blobs, err := c.layerBlobs()
+	if err != nil {
+		return nil, err
+	}
+	var res []Entry
+	for _, f := range blobs {
+		res = append(res, c.newEntry(f.sha256sum, f.info))
+	}
+	return res, nil
+}
+
+// Inspect inspects a cached blob.
+// Returns os.ErrNotExist if the blob is not cached.
+func (c *Cache) Inspect(sha256sum string) (*Entry, error) {
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(blob)
+	if err != nil {
+		return nil, err
+	}
+	ent := c.newEntry(sha256sum, st)
+	return &ent, nil
+}
+
+func (c *Cache) newEntry(sha256sum string, st os.FileInfo) Entry {
+	ent := Entry{
+		SHA256:   sha256sum,
+		Size:     st.Size(),
+		LastUsed: st.ModTime(),
+	}
+	m, err := c.MetadataBySHA256(sha256sum)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithError(err).Warnf("Failed to read the metadata of %s", sha256sum)
+		}
+		return ent
+	}
+	ent.Metadata = m
+	for _, o := range m.Origins {
+		if !containsString(ent.URLs, o.URL) {
+			ent.URLs = append(ent.URLs, o.URL)
+		}
+	}
+	if m.Basename != "" {
+		sp, err := filespec.New(m.Basename, sha256sum)
+		if err != nil {
+			logrus.WithError(err).Debugf("Failed to parse the package identity of %q", m.Basename)
+		}
+		ent.FileSpec = sp // non-nil even on a parse error
+	}
+	return ent
+}
+
+// SHA256sByBasenames returns the index of the basenames in the metadata of the cached blobs.
+// The map key is a basename, and the map value is the sorted sha256sums of the blobs that have the basename.
+// A basename may have multiple sha256sums, as a basename is not unique.
+//
+// The metadata files are read at once, so the caller should keep the index rather than calling this per lookup.
+func (c *Cache) SHA256sByBasenames() (map[string][]string, error) {
+	unlockCache, err := c.lockCache(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockCache()
+	blobs, err := c.layerBlobs()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]string)
+	for _, f := range blobs {
+		m, err := c.MetadataBySHA256(f.sha256sum)
+		if err != nil {
+			logrus.WithError(err).Debugf("Failed to read the metadata of %s", f.sha256sum)
+			continue
+		}
+		for _, basename := range m.Basenames {
+			res[basename] = append(res[basename], f.sha256sum) // sorted, as layerBlobs sorts the blobs
+		}
+	}
+	return res, nil
+}
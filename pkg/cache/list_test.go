@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheList(t *testing.T) {
+	const debBasename = "hello_2.10-2_amd64.deb"
+	blobsBySHA256 := newTestBlobs(debBasename, "foo")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	var deb, foo *testBlob
+	for _, blob := range blobsBySHA256 {
+		m := &Metadata{Basename: blob.basename}
+		assert.NilError(t, cache.Ensure(context.TODO(), testServer.basenameURL(blob), blob.sha256, m))
+		if blob.basename == debBasename {
+			deb = blob
+		} else {
+			foo = blob
+		}
+	}
+	// The reverse URL file must only contain the digest, for the compatibility with older versions
+	revURLFile, err := cache.ReverseURLFileAbsPath(testServer.basenameURL(deb))
+	assert.NilError(t, err)
+	b, err := os.ReadFile(revURLFile)
+	assert.NilError(t, err)
+	assert.Equal(t, "sha256:"+deb.sha256, string(b))
+	// Reverse URL file with the URL line, written by some development versions
+	devURL := testServer.digestURL(foo)
+	devRevURLFile, err := cache.ReverseURLFileAbsPath(devURL)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(devRevURLFile, []byte("sha256:"+foo.sha256+"\n"+devURL.String()+"\n"), 0644))
+	sha256sum, err := cache.SHA256ByOriginURL(devURL)
+	assert.NilError(t, err)
+	assert.Equal(t, foo.sha256, sha256sum)
+
+	ents, err := cache.List()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(ents))
+	for _, ent := range ents {
+		blob := blobsBySHA256[ent.SHA256]
+		assert.Equal(t, int64(len(blob.b)), ent.Size)
+		assert.Equal(t, blob.basename, ent.Metadata.Basename)
+		assert.DeepEqual(t, []string{urlopener.Redacted(testServer.basenameURL(blob))}, ent.URLs)
+		assert.Check(t, !ent.LastUsed.IsZero())
+	}
+
+	ent, err := cache.Inspect(deb.sha256)
+	assert.NilError(t, err)
+	assert.Equal(t, debBasename, ent.FileSpec.Basename)
+	assert.Equal(t, "hello", ent.FileSpec.Dpkg.Package)
+	assert.Equal(t, "2.10-2", ent.FileSpec.Dpkg.Version)
+
+	ent, err = cache.Inspect(foo.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, ent.FileSpec.Dpkg == nil)
+
+	_, err = cache.Inspect(newTestBlob("missing").sha256)
+	assert.Check(t, errors.Is(err, os.ErrNotExist), err)
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"path/filepath"
+
+	"github.com/reproducible-containers/repro-get/pkg/filelock"
+	"github.com/sirupsen/logrus"
+)
+
+// lockCache acquires the cache-wide lock.
+// The lock is shared for adding blobs, and exclusive for removing blobs.
+func (c *Cache) lockCache(exclusive bool) (unlock func(), err error) {
+	f := filepath.Join(c.dir, CacheLockRelPath) // no need to use securejoin (const)
+	var l *filelock.Lock
+	if exclusive {
+		l, err = filelock.Exclusive(f)
+	} else {
+		l, err = filelock.Shared(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unlockFunc(l), nil
+}
+
+func (c *Cache) blobLockFile(sha256sum string) string {
+	return filepath.Join(c.dir, BlobLocksSHA256RelPath, sha256sum+".lock") // no need to use securejoin (the caller verifies sha256sum)
+}
+
+// lockBlob serializes the downloads of the same blob across the goroutines and the processes,
+// as they share the same partial file.
+func (c *Cache) lockBlob(sha256sum string) (unlock func(), err error) {
+	l, err := filelock.Exclusive(c.blobLockFile(sha256sum))
+	if err != nil {
+		return nil, err
+	}
+	return unlockFunc(l), nil
+}
+
+// tryLockBlob is similar to lockBlob but returns filelock.ErrWouldBlock instead of blocking.
+func (c *Cache) tryLockBlob(sha256sum string) (unlock func(), err error) {
+	l, err := filelock.TryExclusive(c.blobLockFile(sha256sum))
+	if err != nil {
+		return nil, err
+	}
+	return unlockFunc(l), nil
+}
+
+func unlockFunc(l *filelock.Lock) func() {
+	return func() {
+		if err := l.Unlock(); err != nil {
+			logrus.WithError(err).Warn("Failed to unlock")
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// newSlowTestServer returns a server that takes a while to serve the blob,
+// so that the concurrent downloads overlap.
+func newSlowTestServer(blob *testBlob) (*httptest.Server, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write(blob.b)
+	}))
+	return srv, &requests
+}
+
+func TestCacheEnsureConcurrent(t *testing.T) {
+	blob := newTestBlob("foo")
+	srv, requests := newSlowTestServer(blob)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/foo")
+	assert.NilError(t, err)
+	cacheDir := t.TempDir()
+
+	const n = 8
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each goroutine has its own Cache object, as if it were a separate process
+			cache, err := New(cacheDir)
+			assert.Check(t, err)
+			assert.Check(t, cache.Ensure(context.TODO(), u, blob.sha256, &Metadata{Basename: blob.basename}))
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// The metadata must not be half-written at any moment
+	cache, err := New(cacheDir)
+	assert.NilError(t, err)
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		if m, err := cache.MetadataBySHA256(blob.sha256); err == nil {
+			assert.Equal(t, blob.basename, m.Basename)
+		} else {
+			assert.Check(t, os.IsNotExist(err), err)
+		}
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	assert.NilError(t, cache.VerifyBlob(blob.sha256))
+}
+
+const (
+	helperEnvCacheDir = "TEST_CACHE_HELPER_CACHE_DIR"
+	helperEnvURL      = "TEST_CACHE_HELPER_URL"
+	helperEnvSHA256   = "TEST_CACHE_HELPER_SHA256"
+)
+
+// TestCacheEnsureHelperProcess is executed as a child process of TestCacheEnsureMultiProcess.
+func TestCacheEnsureHelperProcess(t *testing.T) {
+	cacheDir := os.Getenv(helperEnvCacheDir)
+	if cacheDir == "" {
+		t.Skip("not a helper process")
+	}
+	u, err := url.Parse(os.Getenv(helperEnvURL))
+	assert.NilError(t, err)
+	cache, err := New(cacheDir)
+	assert.NilError(t, err)
+	assert.NilError(t, cache.Ensure(context.TODO(), u, os.Getenv(helperEnvSHA256), &Metadata{Basename: "foo"}))
+}
+
+func TestCacheEnsureMultiProcess(t *testing.T) {
+	blob := newTestBlob("foo")
+	srv, requests := newSlowTestServer(blob)
+	defer srv.Close()
+	cacheDir := t.TempDir()
+
+	const n = 4
+	var (
+		cmds    []*exec.Cmd
+		outputs []*bytes.Buffer
+	)
+	for i := 0; i < n; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCacheEnsureHelperProcess$", "-test.count=1")
+		cmd.Env = append(os.Environ(),
+			helperEnvCacheDir+"="+cacheDir,
+			helperEnvURL+"="+srv.URL+"/foo",
+			helperEnvSHA256+"="+blob.sha256,
+		)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		assert.NilError(t, cmd.Start())
+		cmds = append(cmds, cmd)
+		outputs = append(outputs, &output)
+	}
+	for i, cmd := range cmds {
+		assert.NilError(t, cmd.Wait(), outputs[i].String())
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	cache, err := New(cacheDir)
+	assert.NilError(t, err)
+	assert.NilError(t, cache.VerifyBlob(blob.sha256))
+	m, err := cache.MetadataBySHA256(blob.sha256)
+	assert.NilError(t, err)
+	assert.Equal(t, blob.basename, m.Basename)
+}
+
+func TestCacheGCWaitsForEnsure(t *testing.T) {
+	blob := newTestBlob("foo")
+	srv, requests := newSlowTestServer(blob)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/foo")
+	assert.NilError(t, err)
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+
+	ensured := make(chan error, 1)
+	go func() {
+		ensured <- cache.Ensure(context.TODO(), u, blob.sha256, nil)
+	}()
+	// Wait for Ensure to acquire the shared lock and to start downloading
+	for atomic.LoadInt32(requests) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	res, err := cache.GC([]string{blob.sha256}, GCOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(res.RemovedBlobs))
+	// The blob must have been already committed by Ensure, if GC waited for Ensure.
+	// Note that Ensure may not have returned yet, as it returns after releasing the lock.
+	cached, err := cache.Cached(blob.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, cached, "GC must wait for Ensure")
+	assert.NilError(t, <-ensured)
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+)
+
+// MetadataVersion is the version of the metadata format written by this version of repro-get.
+//
+//   - 0: only has Basename
+//   - 1: has Basenames, Origins, Size, and FirstFetched too
+const MetadataVersion = 1
+
+// Metadata is the metadata of a blob, stored in MetadataSHA256RelPath.
+type Metadata struct {
+	Version int `json:"Version,omitempty"` // MetadataVersion
+
+	// Basename is the first basename that the blob has been seen under.
+	// Kept for the compatibility with the version 0.
+	Basename string `json:"Basename"`
+
+	Basenames    []string   `json:"Basenames,omitempty"`    // All the basenames that the blob has been seen under, sorted
+	Origins      []Origin   `json:"Origins,omitempty"`      // All the origins that the blob has been fetched from, in the order of the first fetch
+	Size         int64      `json:"Size,omitempty"`         // Size of the blob
+	FirstFetched *time.Time `json:"FirstFetched,omitempty"` // Time when the blob was added to the cache for the first time (nil if upgraded from the version 0)
+}
+
+// Origin is an origin of a blob.
+type Origin struct {
+	URL          string     `json:"URL"`                    // Redacted
+	Provider     string     `json:"Provider,omitempty"`     // Provider template string that produced the URL (redacted), e.g., "http://deb.debian.org/debian/{{.Name}}"
+	FirstFetched *time.Time `json:"FirstFetched,omitempty"` // Time when the blob was fetched from the URL for the first time
+}
+
+func ValidateMetadata(m *Metadata) error {
+	if m == nil {
+		return nil
+	}
+	if m.Version > MetadataVersion {
+		return fmt.Errorf("unsupported metadata version %d (supported: %d)", m.Version, MetadataVersion)
+	}
+	for _, basename := range append([]string{m.Basename}, m.Basenames...) {
+		if basename != "" && filepath.Base(basename) != basename {
+			return fmt.Errorf("invalid basename: %q", basename)
+		}
+	}
+	return nil
+}
+
+// ParseMetadata parses the metadata.
+// The legacy format (version 0) is converted to the current format.
+func ParseMetadata(b []byte) (*Metadata, error) {
+	var m Metadata
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if err := ValidateMetadata(&m); err != nil {
+		return nil, err
+	}
+	if m.Version == 0 {
+		m.Version = MetadataVersion
+		if m.Basename != "" {
+			m.Basenames = []string{m.Basename}
+		}
+	}
+	return &m, nil
+}
+
+// merge merges the basename of in, the origin URL u, and the provider into m.
+// Other fields of in are ignored.
+func (m *Metadata) merge(in *Metadata, u *url.URL, provider string, now time.Time) {
+	m.Version = MetadataVersion
+	if in != nil && in.Basename != "" {
+		if m.Basename == "" {
+			m.Basename = in.Basename
+		}
+		if !containsString(m.Basenames, in.Basename) {
+			m.Basenames = append(m.Basenames, in.Basename)
+			sort.Strings(m.Basenames)
+		}
+	}
+	if u != nil {
+		redacted := urlopener.Redacted(u)
+		for i := range m.Origins {
+			if o := &m.Origins[i]; o.URL == redacted {
+				if o.Provider == "" && provider != "" {
+					o.Provider = urlopener.RedactedString(provider)
+				}
+				return
+			}
+		}
+		o := Origin{
+			URL:          redacted,
+			FirstFetched: &now,
+		}
+		if provider != "" {
+			o.Provider = urlopener.RedactedString(provider)
+		}
+		m.Origins = append(m.Origins, o)
+	}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, f := range ss {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
+
+// writeMetadataFile merges the metadata into the existing metadata file, and writes it to the writable layer.
+// The caller must hold the blob lock.
+func (c *Cache) writeMetadataFile(sha256sum string, u *url.URL, in *Metadata, provider string) error {
+	if err := ValidateMetadata(in); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	var orig []byte
+	m, err := c.MetadataBySHA256(sha256sum)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithError(err).Warnf("Failed to read the metadata of %s, overwriting", sha256sum)
+		}
+		// FirstFetched is only set for a new metadata file.
+		// The metadata upgraded from the version 0 does not have it, as the time is unknown.
+		m = &Metadata{FirstFetched: &now}
+	} else if orig, err = json.Marshal(m); err != nil {
+		return err
+	}
+	m.merge(in, u, provider, now)
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	if st, err := os.Stat(blob); err == nil {
+		m.Size = st.Size()
+	}
+	j, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(orig, j) {
+		// Nothing to record, e.g., on a cache hit
+		return nil
+	}
+	metadataFileAbs, err := c.MetadataFileAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(metadataFileAbs, j, 0644); err != nil {
+		return fmt.Errorf("failed to create %q: %w", metadataFileAbs, err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/reproducible-containers/repro-get/pkg/filelink"
+	"github.com/reproducible-containers/repro-get/pkg/ociartifact"
+	"github.com/sirupsen/logrus"
+)
+
+// ociLayoutIndexFile is the file name of the entry point for references and descriptors of an OCI image layout.
+const ociLayoutIndexFile = "index.json"
+
+// IsOCILayout returns true if dir contains the "oci-layout" file.
+func IsOCILayout(dir string) bool {
+	st, err := os.Stat(filepath.Join(dir, ocispec.ImageLayoutFile)) // no need to use securejoin (const)
+	return err == nil && !st.IsDir()
+}
+
+// ExportOCILayout exports the cached blobs in all the layers to dir as an OCI image layout.
+// The blobs are listed in a single artifact manifest (see package ociartifact),
+// with their original basenames in the "org.opencontainers.image.title" annotations.
+//
+// refName is set to the "org.opencontainers.image.ref.name" annotation of the manifest in index.json, if non-empty.
+//
+// The output is deterministic for the same set of blobs and metadata.
+func (c *Cache) ExportOCILayout(dir, refName string, options ...ExportOption) ([]ociartifact.File, error) {
+	opts := newExportOpts(options)
+	if _, err := filelink.ParseMode(string(opts.linkMode)); err != nil {
+		return nil, err
+	}
+	blobs, err := c.layerBlobs()
+	if err != nil {
+		return nil, err
+	}
+	indexJSON := filepath.Join(dir, ociLayoutIndexFile) // no need to use securejoin (const)
+	if _, err := os.Lstat(indexJSON); !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("avoiding to overwrite existing file %q", indexJSON)
+	}
+	dstBlobsDir := filepath.Join(dir, "blobs", "sha256") // no need to use securejoin (const)
+	if err := os.MkdirAll(dstBlobsDir, 0755); err != nil {
+		return nil, err
+	}
+	var files []ociartifact.File
+	for _, f := range blobs {
+		sha256sum := f.sha256sum
+		file := ociartifact.File{
+			SHA256: sha256sum,
+			Size:   f.info.Size(),
+		}
+		if m, err := c.MetadataBySHA256(sha256sum); err == nil && m.Basename != "" {
+			file.Basename = filepath.Base(m.Basename)
+		} else {
+			logrus.WithError(err).Warnf("Failed to get the original basename of %s", sha256sum)
+		}
+		cpDst := filepath.Join(dstBlobsDir, sha256sum) // no need to use securejoin (sha256sum is verified)
+		if err = opts.link(cpDst, f.path, sha256sum); err != nil {
+			return files, err
+		}
+		files = append(files, file)
+	}
+
+	emptyConfig := ocispec.DescriptorEmptyJSON
+	if err = writeOCILayoutBlob(dstBlobsDir, emptyConfig.Digest, ociartifact.EmptyConfig); err != nil {
+		return files, err
+	}
+	manifest := ociartifact.NewManifest(files)
+	manifestJSON, manifestDesc, err := ociartifact.MarshalManifest(manifest)
+	if err != nil {
+		return files, err
+	}
+	if err = writeOCILayoutBlob(dstBlobsDir, manifestDesc.Digest, manifestJSON); err != nil {
+		return files, err
+	}
+	if refName != "" {
+		manifestDesc.Annotations = map[string]string{
+			ocispec.AnnotationRefName: refName,
+		}
+	}
+	index := ocispec.Index{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{manifestDesc},
+	}
+	indexB, err := json.Marshal(index)
+	if err != nil {
+		return files, err
+	}
+	layout := ocispec.ImageLayout{
+		Version: ocispec.ImageLayoutVersion,
+	}
+	layoutB, err := json.Marshal(layout)
+	if err != nil {
+		return files, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, ocispec.ImageLayoutFile), layoutB, 0644); err != nil { // no need to use securejoin (const)
+		return files, err
+	}
+	// index.json is written in the last, so that incomplete layouts are not recognized
+	if err = os.WriteFile(indexJSON, indexB, 0644); err != nil {
+		return files, err
+	}
+	return files, nil
+}
+
+func writeOCILayoutBlob(blobsDir string, dgst digest.Digest, b []byte) error {
+	if err := dgst.Validate(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(blobsDir, dgst.Encoded()), b, 0644) // no need to use securejoin (dgst is verified)
+}
+
+// ImportOCILayout imports the blobs of the artifact manifests in the OCI image layout.
+// Manifests that are not image manifests (e.g., nested indexes) are ignored.
+// Blobs are verified with their digests.
+func (c *Cache) ImportOCILayout(dir string) ([]ociartifact.File, error) {
+	if !IsOCILayout(dir) {
+		return nil, fmt.Errorf("%q is not an OCI image layout (missing %q)", dir, ocispec.ImageLayoutFile)
+	}
+	indexB, err := os.ReadFile(filepath.Join(dir, ociLayoutIndexFile)) // no need to use securejoin (const)
+	if err != nil {
+		return nil, err
+	}
+	var index ocispec.Index
+	if err = json.Unmarshal(indexB, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", ociLayoutIndexFile, err)
+	}
+	var imported []ociartifact.File
+	for _, manifestDesc := range index.Manifests {
+		if manifestDesc.MediaType != ocispec.MediaTypeImageManifest {
+			logrus.Warnf("Ignoring %s (%s)", manifestDesc.Digest, manifestDesc.MediaType)
+			continue
+		}
+		manifestB, err := readOCILayoutBlob(dir, manifestDesc.Digest)
+		if err != nil {
+			return imported, err
+		}
+		var manifest ocispec.Manifest
+		if err = json.Unmarshal(manifestB, &manifest); err != nil {
+			return imported, fmt.Errorf("failed to parse manifest %s: %w", manifestDesc.Digest, err)
+		}
+		files, err := ociartifact.Files(&manifest)
+		if err != nil {
+			return imported, fmt.Errorf("failed to parse manifest %s: %w", manifestDesc.Digest, err)
+		}
+		for _, file := range files {
+			if err = c.importOCILayoutFile(dir, file); err != nil {
+				return imported, err
+			}
+			imported = append(imported, file)
+		}
+	}
+	return imported, nil
+}
+
+func (c *Cache) importOCILayoutFile(dir string, file ociartifact.File) error {
+	var m *Metadata
+	if file.Basename != "" {
+		m = &Metadata{
+			Basename: file.Basename,
+		}
+		if err := ValidateMetadata(m); err != nil {
+			return err
+		}
+	}
+	r, err := os.Open(filepath.Join(dir, "blobs", "sha256", file.SHA256)) // no need to use securejoin (SHA256 is verified)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	sha256sum, err := c.importWithReader(r, file.SHA256)
+	if err != nil {
+		return err
+	}
+	unlock, err := c.lockBlob(sha256sum)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return c.writeMetadataFiles(sha256sum, nil, m, "")
+}
+
+func readOCILayoutBlob(dir string, dgst digest.Digest) ([]byte, error) {
+	if dgst.Algorithm() != digest.SHA256 {
+		return nil, fmt.Errorf("expected algorithm %q, got %q (%q)", digest.SHA256, dgst.Algorithm(), dgst)
+	}
+	if err := dgst.Validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, "blobs", "sha256", dgst.Encoded())) // no need to use securejoin (dgst is verified)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	verifier := dgst.Verifier()
+	b, err := io.ReadAll(io.TeeReader(f, verifier))
+	if err != nil {
+		return nil, err
+	}
+	if !verifier.Verified() {
+		return nil, fmt.Errorf("digest mismatch: %s", dgst)
+	}
+	return b, nil
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheOCILayout(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	for _, blob := range blobsBySHA256 {
+		// Two blobs share the same basename, which cannot be exported with Export
+		m := &Metadata{Basename: "hello_2.10-2_amd64.deb"}
+		assert.NilError(t, cache.Ensure(context.TODO(), testServer.basenameURL(blob), blob.sha256, m))
+	}
+
+	layoutDir := t.TempDir()
+	exported, err := cache.ExportOCILayout(layoutDir, "latest")
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(exported))
+	assert.Check(t, IsOCILayout(layoutDir))
+	indexB, err := os.ReadFile(filepath.Join(layoutDir, "index.json"))
+	assert.NilError(t, err)
+
+	// Exporting again must not overwrite the existing layout
+	_, err = cache.ExportOCILayout(layoutDir, "latest")
+	assert.ErrorContains(t, err, "avoiding to overwrite")
+
+	// The output is deterministic
+	layoutDir2 := t.TempDir()
+	_, err = cache.ExportOCILayout(layoutDir2, "latest")
+	assert.NilError(t, err)
+	indexB2, err := os.ReadFile(filepath.Join(layoutDir2, "index.json"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(indexB), string(indexB2))
+
+	cache2, err := New(t.TempDir())
+	assert.NilError(t, err)
+	imported, err := cache2.ImportOCILayout(layoutDir)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(imported))
+	for _, blob := range blobsBySHA256 {
+		cached, err := cache2.Cached(blob.sha256)
+		assert.NilError(t, err)
+		assert.Check(t, cached)
+		m, err := cache2.MetadataBySHA256(blob.sha256)
+		assert.NilError(t, err)
+		assert.Equal(t, "hello_2.10-2_amd64.deb", m.Basename)
+	}
+
+	// Corrupt a blob
+	corrupted := imported[0].SHA256
+	assert.NilError(t, os.WriteFile(filepath.Join(layoutDir, "blobs", "sha256", corrupted), []byte("corrupted"), 0644))
+	cache3, err := New(t.TempDir())
+	assert.NilError(t, err)
+	_, err = cache3.ImportOCILayout(layoutDir)
+	assert.ErrorContains(t, err, "expected sha256sum")
+	cached, err := cache3.Cached(corrupted)
+	assert.NilError(t, err)
+	assert.Check(t, !cached)
+}
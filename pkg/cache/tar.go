@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/ociartifact"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// TarSHA256SUMS is the name of the first entry of the tar stream (see ExportTar).
+const TarSHA256SUMS = "SHA256SUMS"
+
+// ExportTar exports the cached blobs in all the layers to w as a tar stream.
+//
+// The tar stream consists of:
+//
+//   - SHA256SUMS: "<SHA256>  <BASENAME>" lines. "<SHA256>  blobs/sha256/<SHA256>" if the basename is unknown.
+//
+//   - blobs/sha256/<SHA256>: the blobs, sorted by the sha256sums.
+//
+// The stream is deterministic for the same set of blobs and metadata:
+// the timestamps are fixed to the Unix epoch, and the owners are fixed to 0:0.
+func (c *Cache) ExportTar(w io.Writer) ([]ociartifact.File, error) {
+	unlockCache, err := c.lockCache(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockCache()
+	blobs, err := c.layerBlobs()
+	if err != nil {
+		return nil, err
+	}
+	var files []ociartifact.File
+	for _, f := range blobs {
+		file := ociartifact.File{
+			SHA256: f.sha256sum,
+			Size:   f.info.Size(),
+		}
+		if m, err := c.MetadataBySHA256(f.sha256sum); err == nil && m.Basename != "" {
+			file.Basename = filepath.Base(m.Basename)
+		} else {
+			logrus.WithError(err).Warnf("Failed to get the original basename of %s", f.sha256sum)
+		}
+		files = append(files, file)
+	}
+
+	var sums bytes.Buffer
+	sumsFiles := append([]ociartifact.File(nil), files...)
+	sort.SliceStable(sumsFiles, func(i, j int) bool {
+		return tarSHA256SUMSName(sumsFiles[i]) < tarSHA256SUMSName(sumsFiles[j])
+	})
+	for _, f := range sumsFiles {
+		fmt.Fprintf(&sums, "%s  %s\n", f.SHA256, tarSHA256SUMSName(f))
+	}
+
+	tw := tar.NewWriter(w)
+	if err = tw.WriteHeader(newTarHeader(TarSHA256SUMS, int64(sums.Len()))); err != nil {
+		return nil, err
+	}
+	if _, err = tw.Write(sums.Bytes()); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if err = c.exportTarBlob(tw, f); err != nil {
+			return files, err
+		}
+	}
+	return files, tw.Close()
+}
+
+func tarSHA256SUMSName(f ociartifact.File) string {
+	if f.Basename == "" {
+		return path.Join(BlobsSHA256RelPath, f.SHA256)
+	}
+	return f.Basename
+}
+
+func newTarHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  time.Unix(0, 0),
+		Format:   tar.FormatUSTAR,
+	}
+}
+
+func (c *Cache) exportTarBlob(tw *tar.Writer, f ociartifact.File) error {
+	blob, err := c.BlobAbsPath(f.SHA256)
+	if err != nil {
+		return err
+	}
+	r, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	st, err := r.Stat()
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(newTarHeader(path.Join(BlobsSHA256RelPath, f.SHA256), st.Size())); err != nil {
+		return err
+	}
+	if _, err = io.CopyN(tw, r, st.Size()); err != nil {
+		return fmt.Errorf("failed to export %s: %w", f.SHA256, err)
+	}
+	return nil
+}
+
+// ImportTar imports the tar stream created by ExportTar.
+// The digest of each blob is verified while reading the stream.
+// Entries that are not listed in SHA256SUMS, or that do not match the digests, are rejected.
+func (c *Cache) ImportTar(r io.Reader) ([]ociartifact.File, error) {
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the first entry: %w", err)
+	}
+	if hdr.Name != TarSHA256SUMS {
+		return nil, fmt.Errorf("expected the first entry to be %q, got %q", TarSHA256SUMS, hdr.Name)
+	}
+	basenames, err := parseTarSHA256SUMS(tr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", TarSHA256SUMS, err)
+	}
+	var imported []ociartifact.File
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return imported, fmt.Errorf("unexpected entry %q (type %q)", hdr.Name, hdr.Typeflag)
+		}
+		sha256sum := strings.TrimPrefix(hdr.Name, BlobsSHA256RelPath+"/")
+		if sha256sum == hdr.Name || digest.SHA256.Validate(sha256sum) != nil {
+			return imported, fmt.Errorf("unexpected entry %q", hdr.Name)
+		}
+		basename, ok := basenames[sha256sum]
+		if !ok {
+			return imported, fmt.Errorf("entry %q is not listed in %q", hdr.Name, TarSHA256SUMS)
+		}
+		if _, err = c.importWithReader(tr, sha256sum); err != nil {
+			return imported, fmt.Errorf("failed to import %q: %w", hdr.Name, err)
+		}
+		var m *Metadata
+		if basename != "" {
+			m = &Metadata{
+				Basename: basename,
+			}
+		}
+		unlock, err := c.lockBlob(sha256sum)
+		if err != nil {
+			return imported, err
+		}
+		err = c.writeMetadataFiles(sha256sum, nil, m, "")
+		unlock()
+		if err != nil {
+			return imported, err
+		}
+		imported = append(imported, ociartifact.File{
+			SHA256:   sha256sum,
+			Size:     hdr.Size,
+			Basename: basename,
+		})
+		delete(basenames, sha256sum)
+	}
+	if len(basenames) > 0 {
+		return imported, fmt.Errorf("%d files listed in %q are missing in the stream", len(basenames), TarSHA256SUMS)
+	}
+	return imported, nil
+}
+
+// parseTarSHA256SUMS parses SHA256SUMS in the tar stream, and returns map[sha256sum]basename.
+// The basename is empty if it is unknown.
+func parseTarSHA256SUMS(r io.Reader) (map[string]string, error) {
+	// sha256sums.Parse is not used here, as the basenames are not unique
+	sc := bufio.NewScanner(r)
+	res := make(map[string]string)
+	for i := 0; sc.Scan(); i++ {
+		sha256sum, name, err := sha256sums.ParseLine(sc.Text())
+		if err != nil {
+			if errors.Is(err, sha256sums.ErrEmptyLine) || errors.Is(err, sha256sums.ErrCommentLine) {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if err = digest.SHA256.Validate(sha256sum); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		basename := name
+		if name == path.Join(BlobsSHA256RelPath, sha256sum) {
+			basename = ""
+		} else if err = ValidateMetadata(&Metadata{Basename: basename}); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		res[sha256sum] = basename
+	}
+	return res, sc.Err()
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheTar(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar", "baz")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	for _, blob := range blobsBySHA256 {
+		var m *Metadata
+		if blob.basename != "baz" {
+			// Two blobs share the same basename
+			m = &Metadata{Basename: "hello_2.10-2_amd64.deb"}
+		}
+		assert.NilError(t, cache.Ensure(context.TODO(), testServer.digestURL(blob), blob.sha256, m))
+	}
+
+	var tarBuf bytes.Buffer
+	exported, err := cache.ExportTar(&tarBuf)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(exported))
+
+	// The stream is deterministic
+	var tarBuf2 bytes.Buffer
+	_, err = cache.ExportTar(&tarBuf2)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, tarBuf.Bytes(), tarBuf2.Bytes())
+
+	cache2, err := New(t.TempDir())
+	assert.NilError(t, err)
+	imported, err := cache2.ImportTar(bytes.NewReader(tarBuf.Bytes()))
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(imported))
+	for _, blob := range blobsBySHA256 {
+		cached, err := cache2.Cached(blob.sha256)
+		assert.NilError(t, err)
+		assert.Check(t, cached)
+		m, err := cache2.MetadataBySHA256(blob.sha256)
+		assert.NilError(t, err)
+		assert.Equal(t, int64(len(blob.b)), m.Size)
+		if blob.basename == "baz" {
+			assert.Equal(t, "", m.Basename)
+		} else {
+			assert.Equal(t, "hello_2.10-2_amd64.deb", m.Basename)
+		}
+	}
+}
+
+func TestCacheImportTarTampered(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	var blob *testBlob
+	for _, blob = range blobsBySHA256 {
+		assert.NilError(t, cache.Ensure(context.TODO(), testServer.digestURL(blob), blob.sha256, nil))
+	}
+	var tarBuf bytes.Buffer
+	_, err = cache.ExportTar(&tarBuf)
+	assert.NilError(t, err)
+
+	// Replace the content of the blob entry with another content of the same size
+	tampered := rewriteTar(t, tarBuf.Bytes(), func(hdr *tar.Header, b []byte) []byte {
+		if hdr.Name == TarSHA256SUMS {
+			return b
+		}
+		return bytes.ToUpper(b)
+	})
+	cache2, err := New(t.TempDir())
+	assert.NilError(t, err)
+	_, err = cache2.ImportTar(bytes.NewReader(tampered))
+	assert.ErrorContains(t, err, "expected sha256sum")
+	ents, err := cache2.List()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(ents))
+
+	// Empty SHA256SUMS
+	unlisted := rewriteTar(t, tarBuf.Bytes(), func(hdr *tar.Header, b []byte) []byte {
+		if hdr.Name == TarSHA256SUMS {
+			return []byte{}
+		}
+		return b
+	})
+	_, err = cache2.ImportTar(bytes.NewReader(unlisted))
+	assert.ErrorContains(t, err, "not listed")
+
+	// Missing SHA256SUMS
+	missing := rewriteTar(t, tarBuf.Bytes(), func(hdr *tar.Header, b []byte) []byte {
+		if hdr.Name == TarSHA256SUMS {
+			return nil
+		}
+		return b
+	})
+	_, err = cache2.ImportTar(bytes.NewReader(missing))
+	assert.ErrorContains(t, err, "expected the first entry")
+}
+
+// rewriteTar rewrites the tar entries with fn.
+// The entry is removed if fn returns nil.
+func rewriteTar(t testing.TB, in []byte, fn func(hdr *tar.Header, b []byte) []byte) []byte {
+	t.Helper()
+	tr := tar.NewReader(bytes.NewReader(in))
+	var out bytes.Buffer
+	tw := tar.NewWriter(&out)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+		b, err := io.ReadAll(tr)
+		assert.NilError(t, err)
+		b = fn(hdr, b)
+		if b == nil {
+			continue
+		}
+		hdr.Size = int64(len(b))
+		assert.NilError(t, tw.WriteHeader(hdr))
+		_, err = tw.Write(b)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, tw.Close())
+	return out.Bytes()
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCorruptedBlob is returned when the digest of a cached blob does not match its file name.
+var ErrCorruptedBlob = errors.New("corrupted blob")
+
+// VerifyBlob re-hashes the blob.
+// Returns an error that wraps ErrCorruptedBlob if the blob is corrupted,
+// or os.ErrNotExist if the blob is not cached.
+func (c *Cache) VerifyBlob(sha256sum string) error {
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	digester := digest.SHA256.Digester()
+	if _, err = io.Copy(digester.Hash(), f); err != nil {
+		return fmt.Errorf("failed to read %q: %w", blob, err)
+	}
+	if actual := digester.Digest().Encoded(); actual != sha256sum {
+		return fmt.Errorf("%w: expected sha256sum %q, got %q", ErrCorruptedBlob, sha256sum, actual)
+	}
+	return nil
+}
+
+// Quarantine moves the blob to QuarantineSHA256RelPath, so that it can be downloaded again.
+// The metadata file and the reverse URL files are kept, as they are still valid.
+//
+// A blob in a read-only layer cannot be moved, so it is just ignored by this Cache instance.
+func (c *Cache) Quarantine(sha256sum string) error {
+	blob, err := c.writableBlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(blob); errors.Is(err, os.ErrNotExist) {
+		if roBlob := c.readOnlyBlobAbsPath(sha256sum); roBlob != "" {
+			logrus.Warnf("Ignoring corrupted blob %q in the read-only layer", roBlob)
+			c.roMu.Lock()
+			c.roMasked[sha256sum] = struct{}{}
+			c.roMu.Unlock()
+			return nil
+		}
+	}
+	unlockCache, err := c.lockCache(false)
+	if err != nil {
+		return err
+	}
+	defer unlockCache()
+	unlock, err := c.lockBlob(sha256sum)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	quarantineDir := filepath.Join(c.dir, QuarantineSHA256RelPath) // no need to use securejoin (const)
+	if err = os.MkdirAll(quarantineDir, 0755); err != nil {
+		return err
+	}
+	dst := filepath.Join(quarantineDir, sha256sum) // no need to use securejoin (sha256sum is verified)
+	logrus.Warnf("Moving corrupted blob %q to %q", blob, dst)
+	return os.Rename(blob, dst)
+}
+
+type VerifyOpts struct {
+	Quarantine bool // Move corrupted blobs to QuarantineSHA256RelPath
+}
+
+type VerifyResult struct {
+	VerifiedBlobs   int      // Number of the verified blobs, including corrupted ones
+	CorruptedBlobs  []string // sha256sums of the corrupted blobs
+	Inconsistencies []string // Human-readable descriptions of inconsistent metadata files and reverse URL files
+}
+
+// Verify re-hashes all the blobs, and checks the consistency of the metadata files and the reverse URL files.
+func (c *Cache) Verify(opts VerifyOpts) (*VerifyResult, error) {
+	var res VerifyResult
+	inconsistent := func(format string, args ...interface{}) {
+		s := fmt.Sprintf(format, args...)
+		logrus.Debug(s)
+		res.Inconsistencies = append(res.Inconsistencies, s)
+	}
+
+	// Blobs
+	blobsDir := filepath.Join(c.dir, BlobsSHA256RelPath) // no need to use securejoin (const)
+	blobs, err := os.ReadDir(blobsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range blobs {
+		sha256sum := f.Name()
+		if strings.HasPrefix(sha256sum, ".") || strings.HasSuffix(sha256sum, ".tmp") {
+			continue
+		}
+		if f.IsDir() || digest.SHA256.Validate(sha256sum) != nil {
+			inconsistent("unexpected file %q", filepath.Join(BlobsSHA256RelPath, sha256sum))
+			continue
+		}
+		res.VerifiedBlobs++
+		if err = c.VerifyBlob(sha256sum); err != nil {
+			if !errors.Is(err, ErrCorruptedBlob) {
+				return nil, err
+			}
+			logrus.WithError(err).Warnf("Blob %s is corrupted", sha256sum)
+			res.CorruptedBlobs = append(res.CorruptedBlobs, sha256sum)
+			if opts.Quarantine {
+				if err = c.Quarantine(sha256sum); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Metadata files
+	metadataDir := filepath.Join(c.dir, MetadataSHA256RelPath) // no need to use securejoin (const)
+	metadataFiles, err := os.ReadDir(metadataDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range metadataFiles {
+		sha256sum := f.Name()
+		rel := filepath.Join(MetadataSHA256RelPath, sha256sum)
+		if strings.HasPrefix(sha256sum, ".") {
+			// tmp files
+			continue
+		}
+		if f.IsDir() || digest.SHA256.Validate(sha256sum) != nil {
+			inconsistent("unexpected file %q", rel)
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(c.dir, rel))
+		if err != nil {
+			return nil, err
+		}
+		var m Metadata
+		if err = json.Unmarshal(b, &m); err != nil {
+			inconsistent("failed to parse %q: %v", rel, err)
+			continue
+		}
+		if err = ValidateMetadata(&m); err != nil {
+			inconsistent("invalid metadata %q: %v", rel, err)
+			continue
+		}
+		if missing, err := c.missing(sha256sum); err != nil {
+			return nil, err
+		} else if missing {
+			inconsistent("metadata %q refers to a missing blob", rel)
+		}
+	}
+
+	// Reverse URL files
+	revURLDir := filepath.Join(c.dir, ReverseURLRelPath) // no need to use securejoin (const)
+	revURLFiles, err := os.ReadDir(revURLDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range revURLFiles {
+		sha256OfURL := f.Name()
+		rel := filepath.Join(ReverseURLRelPath, sha256OfURL)
+		if strings.HasPrefix(sha256OfURL, ".") {
+			// tmp files
+			continue
+		}
+		if f.IsDir() || digest.SHA256.Validate(sha256OfURL) != nil {
+			inconsistent("unexpected file %q", rel)
+			continue
+		}
+		sha256sum, err := readReverseURLFile(filepath.Join(c.dir, rel))
+		if err != nil {
+			inconsistent("failed to parse %q: %v", rel, err)
+			continue
+		}
+		if missing, err := c.missing(sha256sum); err != nil {
+			return nil, err
+		} else if missing {
+			inconsistent("reverse URL file %q refers to a missing blob %s", rel, sha256sum)
+		}
+	}
+	return &res, nil
+}
+
+// missing returns true if the blob is neither cached nor quarantined.
+func (c *Cache) missing(sha256sum string) (bool, error) {
+	if cached, err := c.Cached(sha256sum); err != nil || cached {
+		return false, err
+	}
+	quarantined := filepath.Join(c.dir, QuarantineSHA256RelPath, sha256sum) // no need to use securejoin (sha256sum is verified by Cached)
+	if _, err := os.Stat(quarantined); err == nil {
+		return false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return true, nil
+}
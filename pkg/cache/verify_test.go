@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheVerify(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar", "baz")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	var corrupted *testBlob
+	for _, blob := range blobsBySHA256 {
+		m := &Metadata{Basename: blob.basename}
+		assert.NilError(t, cache.Ensure(context.TODO(), testServer.basenameURL(blob), blob.sha256, m))
+		if blob.basename == "bar" {
+			corrupted = blob
+		}
+	}
+
+	res, err := cache.Verify(VerifyOpts{Quarantine: true})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &VerifyResult{VerifiedBlobs: 3}, res)
+
+	// Flip a bit
+	blobFile, err := cache.BlobAbsPath(corrupted.sha256)
+	assert.NilError(t, err)
+	b := append([]byte{}, corrupted.b...)
+	b[0] ^= 1
+	assert.NilError(t, os.WriteFile(blobFile, b, 0644))
+	assert.Check(t, errors.Is(cache.VerifyBlob(corrupted.sha256), ErrCorruptedBlob))
+
+	res, err = cache.Verify(VerifyOpts{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &VerifyResult{VerifiedBlobs: 3, CorruptedBlobs: []string{corrupted.sha256}}, res)
+	cached, err := cache.Cached(corrupted.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, cached, "the blob must not be moved without VerifyOpts.Quarantine")
+
+	res, err = cache.Verify(VerifyOpts{Quarantine: true})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &VerifyResult{VerifiedBlobs: 3, CorruptedBlobs: []string{corrupted.sha256}}, res)
+	cached, err = cache.Cached(corrupted.sha256)
+	assert.NilError(t, err)
+	assert.Check(t, !cached)
+	_, err = os.Stat(filepath.Join(cache.Dir(), QuarantineSHA256RelPath, corrupted.sha256))
+	assert.NilError(t, err)
+
+	// The quarantined blob can be downloaded again
+	assert.NilError(t, cache.Ensure(context.TODO(), testServer.basenameURL(corrupted), corrupted.sha256, nil))
+	assert.NilError(t, cache.VerifyBlob(corrupted.sha256))
+
+	// Inconsistencies
+	revURLFile, err := cache.ReverseURLFileAbsPath(testServer.basenameURL(corrupted))
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(revURLFile, []byte("invalid"), 0644))
+	metadataFile, err := cache.MetadataFileAbsPath(newTestBlob("missing").sha256)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(metadataFile, []byte(`{"Basename":"missing"}`), 0644))
+	res, err = cache.Verify(VerifyOpts{Quarantine: true})
+	assert.NilError(t, err)
+	assert.Equal(t, 3, res.VerifiedBlobs)
+	assert.Equal(t, 0, len(res.CorruptedBlobs))
+	assert.Equal(t, 2, len(res.Inconsistencies), "%v", res.Inconsistencies)
+}
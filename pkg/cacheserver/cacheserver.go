@@ -0,0 +1,220 @@
+// Package cacheserver serves the cache over HTTP, so that it can be used as a provider by other hosts.
+//
+// URL paths:
+//
+//   - /sha256/<SHA256>: the blob, e.g., for the "http://HOST:PORT/sha256/{{.SHA256}}" provider
+//
+//   - /<NAME>: the blob, e.g., for the "http://HOST:PORT/{{.Name}}" provider
+//
+// A <NAME> is resolved with the hash files (WithFileSpecs), or with the basenames in the cache metadata.
+// The basenames are indexed on New, so the blobs added to the cache by other processes later
+// are not resolved by <NAME> until the server is restarted.
+//
+// The server is read-only, except that it may fill the cache on a GET miss (WithFallThrough).
+package cacheserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+type Option func(s *Server)
+
+// WithFileSpecs specifies the file specs (usually parsed from hash files) to resolve the file names.
+func WithFileSpecs(fileSpecs map[string]*filespec.FileSpec) Option {
+	return func(s *Server) {
+		for name, sp := range fileSpecs {
+			s.byName[name] = sp
+			s.bySHA256[sp.SHA256] = sp
+		}
+	}
+}
+
+// WithFallThrough specifies the upstream providers to fill the cache on a miss.
+// Only the files in the file specs (WithFileSpecs) can be filled, as the digests have to be known.
+func WithFallThrough(providers []string) Option {
+	return func(s *Server) {
+		s.providers = providers
+	}
+}
+
+func New(c *cache.Cache, options ...Option) (*Server, error) {
+	if c == nil {
+		return nil, errors.New("cache is nil")
+	}
+	s := &Server{
+		cache:    c,
+		byName:   make(map[string]*filespec.FileSpec),
+		bySHA256: make(map[string]*filespec.FileSpec),
+	}
+	for _, o := range options {
+		o(s)
+	}
+	var err error
+	s.byBasename, err = c.SHA256sByBasenames()
+	if err != nil {
+		return nil, fmt.Errorf("failed to index the basenames in the cache: %w", err)
+	}
+	return s, nil
+}
+
+// Server implements http.Handler.
+type Server struct {
+	cache     *cache.Cache
+	byName    map[string]*filespec.FileSpec
+	bySHA256  map[string]*filespec.FileSpec
+	providers []string
+
+	byBasenameMu sync.RWMutex
+	byBasename   map[string][]string // built on New, and updated on fill
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sha256sum, status, err := s.resolve(r)
+	if err != nil {
+		logrus.WithError(err).Debugf("Failed to resolve %q", r.URL.Path)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	blob, err := s.cache.BlobAbsPath(sha256sum)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, fmt.Sprintf("sha256:%s is not cached", sha256sum), http.StatusNotFound)
+			return
+		}
+		logrus.WithError(err).Warnf("Failed to open %s", sha256sum)
+		http.Error(w, "failed to open the blob", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	if err = s.cache.Touch(sha256sum); err != nil {
+		logrus.WithError(err).Debugf("Failed to touch %s", sha256sum)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", `"sha256:`+sha256sum+`"`)
+	w.Header().Set("Docker-Content-Digest", "sha256:"+sha256sum)
+	// The modification time is not set, as it is updated for the LRU eviction.
+	// http.ServeContent handles Range, If-Range, and HEAD.
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// resolve resolves the request into a sha256sum, and fills the cache on a miss if WithFallThrough is specified.
+// The returned int is the HTTP status code for the error.
+func (s *Server) resolve(r *http.Request) (string, int, error) {
+	p := path.Clean("/" + r.URL.Path)
+	if p == "/" {
+		return "", http.StatusNotFound, errors.New("no file name specified")
+	}
+	var sp *filespec.FileSpec
+	if strings.HasPrefix(p, "/sha256/") {
+		sha256sum := strings.TrimPrefix(p, "/sha256/")
+		if err := digest.SHA256.Validate(sha256sum); err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		sp = s.bySHA256[sha256sum]
+		if sp == nil {
+			return sha256sum, http.StatusOK, nil
+		}
+	} else {
+		name := strings.TrimPrefix(p, "/")
+		sp = s.byName[name]
+		if sp == nil {
+			s.byBasenameMu.RLock()
+			sha256sums := s.byBasename[path.Base(name)]
+			s.byBasenameMu.RUnlock()
+			switch len(sha256sums) {
+			case 0:
+				return "", http.StatusNotFound, fmt.Errorf("%q is not cached", name)
+			case 1:
+				return sha256sums[0], http.StatusOK, nil
+			default:
+				return "", http.StatusConflict, fmt.Errorf("%q is ambiguous (%d blobs have the same basename, specify the hash file)", name, len(sha256sums))
+			}
+		}
+	}
+	if r.Method != http.MethodGet {
+		// HEAD must not download the whole file, as clients such as `repro-get hash check` probe every file.
+		// A miss results in 404.
+		return sp.SHA256, http.StatusOK, nil
+	}
+	if err := s.fill(sp); err != nil {
+		return "", http.StatusBadGateway, err
+	}
+	return sp.SHA256, http.StatusOK, nil
+}
+
+// fill fills the cache from the upstream providers, if the blob is not cached and WithFallThrough is specified.
+// The request context is not used, so that a disconnected client does not abort the fill
+// that other clients may be waiting for.
+func (s *Server) fill(sp *filespec.FileSpec) error {
+	if len(s.providers) == 0 {
+		return nil
+	}
+	cached, err := s.cache.Cached(sp.SHA256)
+	if err != nil {
+		return err
+	}
+	if cached {
+		return nil
+	}
+	m := &cache.Metadata{
+		Basename: sp.Basename,
+	}
+	var errs []error
+	for _, provider := range s.providers {
+		u, err := sp.URL(provider)
+		if err != nil {
+			logrus.WithError(err).Debugf("Failed to determine the URL of %q with the provider %q", sp.Name, provider)
+			continue
+		}
+		logrus.Infof("Filling the cache with %q (sha256:%s)", sp.Name, sp.SHA256)
+		if err = s.cache.Ensure(context.Background(), u, sp.SHA256, m, cache.WithProvider(provider)); err != nil {
+			logrus.WithError(err).Warnf("Failed to fill the cache with %q", sp.Name)
+			errs = append(errs, err)
+			continue
+		}
+		s.addBasename(sp.Basename, sp.SHA256)
+		return nil
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to fill the cache with %q: %v", sp.Name, errs)
+	}
+	return fmt.Errorf("no provider is available for %q", sp.Name)
+}
+
+func (s *Server) addBasename(basename, sha256sum string) {
+	s.byBasenameMu.Lock()
+	defer s.byBasenameMu.Unlock()
+	sha256sums := s.byBasename[basename]
+	for _, f := range sha256sums {
+		if f == sha256sum {
+			return
+		}
+	}
+	sha256sums = append(sha256sums, sha256sum)
+	sort.Strings(sha256sums)
+	s.byBasename[basename] = sha256sums
+}
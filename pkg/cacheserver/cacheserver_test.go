@@ -0,0 +1,143 @@
+package cacheserver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func get(t testing.TB, method, u string, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, u, nil)
+	assert.NilError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	return resp, b
+}
+
+func TestServer(t *testing.T) {
+	const name = "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	content := []byte("hello-deb")
+	sha256sum := digest.SHA256.FromBytes(content).Encoded()
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	imported, err := c.ImportWithReader(bytes.NewReader(content))
+	assert.NilError(t, err)
+	assert.Equal(t, sha256sum, imported)
+	metadataFile, err := c.MetadataFileAbsPath(sha256sum)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(metadataFile, []byte(`{"Basename":"hello_2.10-2_amd64.deb"}`), 0644))
+	// Unverified tmp files must never be served
+	assert.NilError(t, os.WriteFile(filepath.Join(c.Dir(), cache.BlobsSHA256RelPath, ".download-"+sha256sum+".tmp"), []byte("x"), 0644))
+
+	s, err := New(c)
+	assert.NilError(t, err)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, b := get(t, http.MethodGet, srv.URL+"/sha256/"+sha256sum, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.DeepEqual(t, content, b)
+
+	resp, b = get(t, http.MethodGet, srv.URL+"/"+name, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.DeepEqual(t, content, b)
+
+	resp, b = get(t, http.MethodHead, srv.URL+"/"+name, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(len(content)), resp.ContentLength)
+	assert.Equal(t, 0, len(b))
+
+	resp, b = get(t, http.MethodGet, srv.URL+"/sha256/"+sha256sum, http.Header{"Range": []string{"bytes=6-"}})
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "deb", string(b))
+
+	for _, p := range []string{
+		"/sha256/.download-" + sha256sum + ".tmp",
+		"/sha256/" + strings.Repeat("0", 64),
+		"/pool/main/h/hello/hello_2.10-3_amd64.deb",
+		"/",
+	} {
+		resp, _ = get(t, http.MethodGet, srv.URL+p, nil)
+		assert.Check(t, resp.StatusCode >= 400, "%s: %d", p, resp.StatusCode)
+	}
+
+	resp, _ = get(t, http.MethodDelete, srv.URL+"/sha256/"+sha256sum, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	cached, err := c.Cached(sha256sum)
+	assert.NilError(t, err)
+	assert.Check(t, cached)
+}
+
+func TestServerFallThrough(t *testing.T) {
+	const name = "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	content := []byte("hello-deb")
+	sha256sum := digest.SHA256.FromBytes(content).Encoded()
+
+	var upstreamRequests int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamRequests++
+		if r.URL.Path != "/debian/"+name {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer upstream.Close()
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	sp, err := filespec.New(name, sha256sum)
+	assert.NilError(t, err)
+	s, err := New(c,
+		WithFileSpecs(map[string]*filespec.FileSpec{name: sp}),
+		WithFallThrough([]string{upstream.URL + "/debian/{{.Name}}"}))
+	assert.NilError(t, err)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// HEAD does not fill the cache
+	resp, _ := get(t, http.MethodHead, srv.URL+"/"+name, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 0, upstreamRequests)
+	cached, err := c.Cached(sha256sum)
+	assert.NilError(t, err)
+	assert.Check(t, !cached)
+
+	for i := 0; i < 2; i++ {
+		resp, b := get(t, http.MethodGet, srv.URL+"/sha256/"+sha256sum, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.DeepEqual(t, content, b)
+	}
+	assert.Equal(t, 1, upstreamRequests)
+	cached, err = c.Cached(sha256sum)
+	assert.NilError(t, err)
+	assert.Check(t, cached)
+
+	// The basename index is updated on fill
+	resp, b := get(t, http.MethodGet, srv.URL+"/pool/main/h/hello-old/hello_2.10-2_amd64.deb", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.DeepEqual(t, content, b)
+
+	// Files that are not in the file specs are not filled
+	resp, _ = get(t, http.MethodGet, srv.URL+"/sha256/"+strings.Repeat("0", 64), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 1, upstreamRequests)
+}
@@ -13,6 +13,7 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync"
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/reproducible-containers/repro-get/pkg/apkutil"
@@ -40,14 +41,28 @@ func New() distro.Distro {
 }
 
 type alpine struct {
-	info      distro.Info
-	installed map[string]apkutil.APK
+	info        distro.Info
+	installed   map[string]apkutil.APK // lazily filled by getInstalled
+	installedMu sync.Mutex
 }
 
 func (d *alpine) Info() distro.Info {
 	return d.info
 }
 
+func (d *alpine) getInstalled() (map[string]apkutil.APK, error) {
+	d.installedMu.Lock()
+	defer d.installedMu.Unlock()
+	if d.installed == nil {
+		installed, err := Installed()
+		if err != nil {
+			return nil, err
+		}
+		d.installed = installed
+	}
+	return d.installed, nil
+}
+
 func (d *alpine) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
 	if opts.Cache == nil {
 		return errors.New("cache is required")
@@ -77,10 +92,10 @@ func (d *alpine) GenerateHash(ctx context.Context, hw distro.HashWriter, opts di
 	if err != nil {
 		return fmt.Errorf("failed to execute %v: %w", urlsCmd.Args, err)
 	}
-	return d.generateHashWithURLReader(ctx, hw, opts.Cache, bytes.NewReader(urls))
+	return d.generateHashWithURLReader(ctx, hw, opts, bytes.NewReader(urls))
 }
 
-func (d *alpine) generateHashWithURLReader(ctx context.Context, hw distro.HashWriter, c *cache.Cache, r io.Reader) error {
+func (d *alpine) generateHashWithURLReader(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts, r io.Reader) error {
 	sc := bufio.NewScanner(r)
 	urlOpener := urlopener.New()
 	for sc.Scan() {
@@ -90,7 +105,7 @@ func (d *alpine) generateHashWithURLReader(ctx context.Context, hw distro.HashWr
 		if err != nil {
 			return err
 		}
-		if err := d.generateHashWithURL(ctx, hw, c, urlOpener, u); err != nil {
+		if err := d.generateHashWithURL(ctx, hw, opts, urlOpener, u); err != nil {
 			return err
 		}
 	}
@@ -100,10 +115,11 @@ func (d *alpine) generateHashWithURLReader(ctx context.Context, hw distro.HashWr
 	return nil
 }
 
-func (d *alpine) generateHashWithURL(ctx context.Context, hw distro.HashWriter, c *cache.Cache, urlOpener *urlopener.URLOpener, u *url.URL) error {
-	logrus.Debugf("Generating the hash for %q", u.Redacted())
+func (d *alpine) generateHashWithURL(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts, urlOpener *urlopener.URLOpener, u *url.URL) error {
+	c := opts.Cache
+	logrus.Debugf("Generating the hash for %q", urlopener.Redacted(u))
 	if u.Scheme != "https" {
-		return fmt.Errorf("expected an https url, got %q", u.Redacted())
+		return fmt.Errorf("expected an https url, got %q", urlopener.Redacted(u))
 	}
 	fname, err := urlToFilenameWithoutProvider(u)
 	if err != nil {
@@ -111,12 +127,14 @@ func (d *alpine) generateHashWithURL(ctx context.Context, hw distro.HashWriter,
 	}
 	basename := path.Base(fname)
 	if sha256sum, err := c.SHA256ByOriginURL(u); err == nil {
-		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, u.Redacted())
+		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, urlopener.Redacted(u))
 		return hw(sha256sum, fname)
 	} else if !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
+		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", urlopener.Redacted(u), err)
+	} else if opts.Offline {
+		return fmt.Errorf("%q (%q) is not cached: %w", basename, urlopener.Redacted(u), urlopener.ErrOffline)
 	}
-	logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
+	logrus.Debugf("%q: downloading from %q", basename, urlopener.Redacted(u))
 	m := &cache.Metadata{
 		Basename: basename,
 	}
@@ -138,7 +156,7 @@ func urlToFilenameWithoutProvider(u *url.URL) (string, error) {
 			return strings.Join(sp[i:], "/"), nil
 		}
 	}
-	return "", fmt.Errorf("failed to parse %q", u.Redacted())
+	return "", fmt.Errorf("failed to parse %q", urlopener.Redacted(u))
 }
 
 func (d *alpine) InspectFile(ctx context.Context, sp filespec.FileSpec, opts distro.InspectFileOpts) (*distro.FileInfo, error) {
@@ -151,15 +169,12 @@ func (d *alpine) InspectFile(ctx context.Context, sp filespec.FileSpec, opts dis
 	inf.IsPackage = true
 	inf.PackageName = sp.APK.Package
 	if opts.CheckInstalled {
-		if d.installed == nil {
-			var err error
-			d.installed, err = Installed()
-			if err != nil {
-				return inf, fmt.Errorf("failed to detect installed packages: %w", err)
-			}
+		installedPkgs, err := d.getInstalled()
+		if err != nil {
+			return inf, fmt.Errorf("failed to detect installed packages: %w", err)
 		}
 		k := sp.APK.Package
-		if inst, ok := d.installed[k]; ok {
+		if inst, ok := installedPkgs[k]; ok {
 			installed := inst.Version == sp.APK.Version
 			inf.Installed = &installed
 		}
@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
@@ -41,14 +42,28 @@ func New() distro.Distro {
 }
 
 type arch struct {
-	info      distro.Info
-	installed map[string]pacmanutil.Pacman
+	info        distro.Info
+	installed   map[string]pacmanutil.Pacman // lazily filled by getInstalled
+	installedMu sync.Mutex
 }
 
 func (d *arch) Info() distro.Info {
 	return d.info
 }
 
+func (d *arch) getInstalled() (map[string]pacmanutil.Pacman, error) {
+	d.installedMu.Lock()
+	defer d.installedMu.Unlock()
+	if d.installed == nil {
+		installed, err := Installed()
+		if err != nil {
+			return nil, err
+		}
+		d.installed = installed
+	}
+	return d.installed, nil
+}
+
 func (d *arch) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
 	if opts.Cache == nil {
 		return errors.New("cache is required")
@@ -78,20 +93,20 @@ func (d *arch) GenerateHash(ctx context.Context, hw distro.HashWriter, opts dist
 	if err = cmd.Start(); err != nil {
 		return fmt.Errorf("failed to execute %v: %w", cmd.Args, err)
 	}
-	return d.generateHash(ctx, hw, opts.Cache, r)
+	return d.generateHash(ctx, hw, opts, r)
 }
 
-func (d *arch) generateHash(ctx context.Context, hw distro.HashWriter, c *cache.Cache, r io.Reader) error {
+func (d *arch) generateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts, r io.Reader) error {
 	sc := bufio.NewScanner(r)
 	urlOpener := urlopener.New()
 	for sc.Scan() {
 		line := sc.Text()
 		rawURL := strings.TrimSpace(line)
-		if err := d.generateHash1(ctx, hw, c, urlOpener, rawURL); err != nil {
+		if err := d.generateHash1(ctx, hw, opts, urlOpener, rawURL); err != nil {
 			return err
 		}
 		sigRawURL := rawURL + ".sig"
-		if err := d.generateHash1(ctx, hw, c, urlOpener, sigRawURL); err != nil {
+		if err := d.generateHash1(ctx, hw, opts, urlOpener, sigRawURL); err != nil {
 			return err
 		}
 	}
@@ -101,12 +116,13 @@ func (d *arch) generateHash(ctx context.Context, hw distro.HashWriter, c *cache.
 	return nil
 }
 
-func (d *arch) generateHash1(ctx context.Context, hw distro.HashWriter, c *cache.Cache, uo *urlopener.URLOpener, rawURL string) error {
+func (d *arch) generateHash1(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts, uo *urlopener.URLOpener, rawURL string) error {
+	c := opts.Cache
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return err
 	}
-	logrus.Debugf("Generating the hash for %q", u.Redacted())
+	logrus.Debugf("Generating the hash for %q", urlopener.Redacted(u))
 	basename := path.Base(u.Path)
 	pkg, err := pacmanutil.ParseFilename(strings.TrimSuffix(basename, ".sig"))
 	if err != nil {
@@ -114,12 +130,14 @@ func (d *arch) generateHash1(ctx context.Context, hw distro.HashWriter, c *cache
 	}
 	fname := fmt.Sprintf("%c/%s/%s", pkg.Package[0], pkg.Package, basename)
 	if sha256sum, err := c.SHA256ByOriginURL(u); err == nil {
-		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, u.Redacted())
+		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, urlopener.Redacted(u))
 		return hw(sha256sum, fname)
 	} else if !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
+		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", urlopener.Redacted(u), err)
+	} else if opts.Offline {
+		return fmt.Errorf("%q (%q) is not cached: %w", basename, urlopener.Redacted(u), urlopener.ErrOffline)
 	}
-	logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
+	logrus.Debugf("%q: downloading from %q", basename, urlopener.Redacted(u))
 	m := &cache.Metadata{
 		Basename: basename,
 	}
@@ -152,18 +170,15 @@ func (d *arch) InspectFile(ctx context.Context, sp filespec.FileSpec, opts distr
 	}
 	inf.PackageName = pkg.Package
 	if opts.CheckInstalled {
-		if d.installed == nil {
-			var err error
-			d.installed, err = Installed()
-			if err != nil {
-				return inf, fmt.Errorf("failed to detect installed packages: %w", err)
-			}
+		installedPkgs, err := d.getInstalled()
+		if err != nil {
+			return inf, fmt.Errorf("failed to detect installed packages: %w", err)
 		}
 		k := pkg.Package
 		if pkg.Architecture != "" {
 			k += ":" + pkg.Architecture
 		}
-		if inst, ok := d.installed[k]; ok {
+		if inst, ok := installedPkgs[k]; ok {
 			installed := inst.Version == pkg.Version
 			inf.Installed = &installed
 		}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
@@ -29,62 +30,83 @@ const (
 )
 
 func New() distro.Distro {
+	archivalProviders := []string{
+		// snapshot-cloudflare.debian.org: multi-arch, persistent, slow, experimental (?)
+		"http://snapshot-cloudflare.debian.org/archive/debian/{{timeToDebianSnapshot .Epoch}}/{{.Name}}",
+		"http://snapshot-cloudflare.debian.org/archive/debian-security/{{timeToDebianSnapshot .Epoch}}/{{.Name}}",
+		//
+		// snapshot.debian.org: multi-arch, persistent, very slow
+		"http://snapshot.debian.org/archive/debian/{{timeToDebianSnapshot .Epoch}}/{{.Name}}",
+		"http://snapshot.debian.org/archive/debian-security/{{timeToDebianSnapshot .Epoch}}/{{.Name}}",
+		//
+		// archive.debian.org: multi-arch, persistent, EOL only
+		"http://archive.debian.org/debian/{{.Name}}",
+		"http://archive.debian.org/debian-security/{{.Name}}",
+		//
+		// debian.notset.fr: slow and amd64 only, but accessible by SHA256
+		// Down as of June 2023: https://github.com/fepitre/debian-snapshot/issues/20
+		"http://debian.notset.fr/snapshot/by-hash/SHA256/{{.SHA256}}",
+	}
 	d := &debian{
 		info: distro.Info{
 			Name: NameDebian,
-			DefaultProviders: []string{
+			DefaultProviders: append([]string{
 				// HTTPS is not used by default in the apt-get ecosystem. See also README.md.
 				//
 				// deb.debian.org: multi-arch, ephemeral
 				"http://deb.debian.org/debian/{{.Name}}",
 				"http://deb.debian.org/debian-security/{{.Name}}",
-				//
-				// snapshot-cloudflare.debian.org: multi-arch, persistent, slow, experimental (?)
-				"http://snapshot-cloudflare.debian.org/archive/debian/{{timeToDebianSnapshot .Epoch}}/{{.Name}}",
-				"http://snapshot-cloudflare.debian.org/archive/debian-security/{{timeToDebianSnapshot .Epoch}}/{{.Name}}",
-				//
-				// snapshot.debian.org: multi-arch, persistent, very slow
-				"http://snapshot.debian.org/archive/debian/{{timeToDebianSnapshot .Epoch}}/{{.Name}}",
-				"http://snapshot.debian.org/archive/debian-security/{{timeToDebianSnapshot .Epoch}}/{{.Name}}",
-				//
-				// archive.debian.org: multi-arch, persistent, EOL only
-				"http://archive.debian.org/debian/{{.Name}}",
-				"http://archive.debian.org/debian-security/{{.Name}}",
-				//
-				// debian.notset.fr: slow and amd64 only, but accessible by SHA256
-				// Down as of June 2023: https://github.com/fepitre/debian-snapshot/issues/20
-				"http://debian.notset.fr/snapshot/by-hash/SHA256/{{.SHA256}}",
-			},
+			}, archivalProviders...),
+			ArchivalProviders: archivalProviders,
 		},
 	}
 	return d
 }
 
 func NewUbuntu() distro.Distro {
+	var (
+		launchpad   = "http://launchpad.net/ubuntu/+archive/primary/+files/{{.Basename}}" // multi-arch, persistent
+		oldReleases = "http://old-releases.ubuntu.com/ubuntu/{{.Name}}"                   // multi-arch, persistent, EOL only
+	)
 	d := &debian{
 		info: distro.Info{
 			Name: NameUbuntu,
 			DefaultProviders: []string{
 				// HTTPS is not used by default in the apt-get ecosystem. See also README.md.
-				"http://ports.ubuntu.com/{{.Name}}",                                 // multi-arch, ephemeral
-				"http://launchpad.net/ubuntu/+archive/primary/+files/{{.Basename}}", // multi-arch, persistent
-				"http://archive.ubuntu.com/ubuntu/{{.Name}}",                        // amd64 only, ephemeral
-				"http://old-releases.ubuntu.com/ubuntu/{{.Name}}",                   // multi-arch, persistent, EOL only
+				"http://ports.ubuntu.com/{{.Name}}", // multi-arch, ephemeral
+				launchpad,
+				"http://archive.ubuntu.com/ubuntu/{{.Name}}", // amd64 only, ephemeral
+				oldReleases,
 			},
+			ArchivalProviders: []string{launchpad, oldReleases},
 		},
 	}
 	return d
 }
 
 type debian struct {
-	info      distro.Info
-	installed map[string]dpkgutil.Dpkg
+	info        distro.Info
+	installed   map[string]dpkgutil.Dpkg // lazily filled by getInstalled
+	installedMu sync.Mutex
 }
 
 func (d *debian) Info() distro.Info {
 	return d.info
 }
 
+func (d *debian) getInstalled() (map[string]dpkgutil.Dpkg, error) {
+	d.installedMu.Lock()
+	defer d.installedMu.Unlock()
+	if d.installed == nil {
+		installed, err := Installed()
+		if err != nil {
+			return nil, err
+		}
+		d.installed = installed
+	}
+	return d.installed, nil
+}
+
 func (d *debian) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
 	names := opts.FilterByName
 	if len(names) == 0 {
@@ -178,18 +200,15 @@ func (d *debian) InspectFile(ctx context.Context, sp filespec.FileSpec, opts dis
 	inf.IsPackage = true
 	inf.PackageName = sp.Dpkg.Package
 	if opts.CheckInstalled {
-		if d.installed == nil {
-			var err error
-			d.installed, err = Installed()
-			if err != nil {
-				return inf, fmt.Errorf("failed to detect installed packages: %w", err)
-			}
+		installedPkgs, err := d.getInstalled()
+		if err != nil {
+			return inf, fmt.Errorf("failed to detect installed packages: %w", err)
 		}
 		k := sp.Dpkg.Package
 		if sp.Dpkg.Architecture != "" {
 			k += ":" + sp.Dpkg.Architecture
 		}
-		if inst, ok := d.installed[k]; ok {
+		if inst, ok := installedPkgs[k]; ok {
 			installed := inst.Version == sp.Dpkg.Version
 			inf.Installed = &installed
 		}
@@ -40,6 +40,7 @@ type Distro interface {
 type Info struct {
 	Name                           string   `json:"Name"` // "debian", "ubuntu", ...
 	DefaultProviders               []string `json:"DefaultProviders"`
+	ArchivalProviders              []string `json:"ArchivalProviders,omitempty"` // Subset of DefaultProviders that are persistent but slow (or EOL only)
 	Experimental                   bool     `json:"Experimental"`
 	CacheIsNeededForGeneratingHash bool     `json:"-"` // Implementation detail, not exposed in the JSON
 }
@@ -59,6 +60,7 @@ type InspectFileOpts struct {
 type HashOpts struct {
 	FilterByName []string     // No filter when empty
 	Cache        *cache.Cache // Used only if Info.CacheIsNeededForGeneratingHash is true
+	Offline      bool         // Only use the files that are already cached (for Info.CacheIsNeededForGeneratingHash)
 }
 
 type HashWriter func(sha256sum, filename string) error
@@ -12,6 +12,7 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
@@ -41,14 +42,28 @@ func New() distro.Distro {
 }
 
 type fedora struct {
-	info      distro.Info
-	installed map[string]rpmutil.RPM
+	info        distro.Info
+	installed   map[string]rpmutil.RPM // lazily filled by getInstalled
+	installedMu sync.Mutex
 }
 
 func (d *fedora) Info() distro.Info {
 	return d.info
 }
 
+func (d *fedora) getInstalled() (map[string]rpmutil.RPM, error) {
+	d.installedMu.Lock()
+	defer d.installedMu.Unlock()
+	if d.installed == nil {
+		installed, err := Installed()
+		if err != nil {
+			return nil, err
+		}
+		d.installed = installed
+	}
+	return d.installed, nil
+}
+
 func (d *fedora) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
 	if opts.Cache == nil {
 		return errors.New("cache is required")
@@ -78,10 +93,10 @@ func (d *fedora) GenerateHash(ctx context.Context, hw distro.HashWriter, opts di
 	if err = cmd.Start(); err != nil {
 		return fmt.Errorf("failed to execute %v: %w", cmd.Args, err)
 	}
-	return d.generateHash(ctx, hw, opts.Cache, r)
+	return d.generateHash(ctx, hw, opts, r)
 }
 
-func (d *fedora) generateHash(ctx context.Context, hw distro.HashWriter, c *cache.Cache, r io.Reader) error {
+func (d *fedora) generateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts, r io.Reader) error {
 	const expectedFields = 2
 	sc := bufio.NewScanner(r)
 	urlOpener := urlopener.New()
@@ -110,7 +125,7 @@ func (d *fedora) generateHash(ctx context.Context, hw distro.HashWriter, c *cach
 			continue
 		}
 		fname := fmt.Sprintf("%s/%s/%s/%s/%s", srpm.Package, srpm.Version, srpm.Release, rpm.Architecture, rpmName)
-		if err := d.generateHash1(ctx, hw, c, urlOpener, fname); err != nil {
+		if err := d.generateHash1(ctx, hw, opts, urlOpener, fname); err != nil {
 			return err
 		}
 	}
@@ -120,21 +135,24 @@ func (d *fedora) generateHash(ctx context.Context, hw distro.HashWriter, c *cach
 	return nil
 }
 
-func (d *fedora) generateHash1(ctx context.Context, hw distro.HashWriter, c *cache.Cache, urlOpener *urlopener.URLOpener, fname string) error {
+func (d *fedora) generateHash1(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts, urlOpener *urlopener.URLOpener, fname string) error {
+	c := opts.Cache
 	rawURL := kojiPackages + fname
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return err
 	}
-	logrus.Debugf("Generating the hash for %q", u.Redacted())
+	logrus.Debugf("Generating the hash for %q", urlopener.Redacted(u))
 	basename := path.Base(fname)
 	if sha256sum, err := c.SHA256ByOriginURL(u); err == nil {
-		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, u.Redacted())
+		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, urlopener.Redacted(u))
 		return hw(sha256sum, fname)
 	} else if !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
+		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", urlopener.Redacted(u), err)
+	} else if opts.Offline {
+		return fmt.Errorf("%q (%q) is not cached: %w", basename, urlopener.Redacted(u), urlopener.ErrOffline)
 	}
-	logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
+	logrus.Debugf("%q: downloading from %q", basename, urlopener.Redacted(u))
 	m := &cache.Metadata{
 		Basename: basename,
 	}
@@ -155,18 +173,15 @@ func (d *fedora) InspectFile(ctx context.Context, sp filespec.FileSpec, opts dis
 	inf.IsPackage = true
 	inf.PackageName = sp.RPM.Package
 	if opts.CheckInstalled {
-		if d.installed == nil {
-			var err error
-			d.installed, err = Installed()
-			if err != nil {
-				return inf, fmt.Errorf("failed to detect installed packages: %w", err)
-			}
+		installedPkgs, err := d.getInstalled()
+		if err != nil {
+			return inf, fmt.Errorf("failed to detect installed packages: %w", err)
 		}
 		k := sp.RPM.Package
 		if sp.RPM.Architecture != "" {
 			k += ":" + sp.RPM.Architecture
 		}
-		if inst, ok := d.installed[k]; ok {
+		if inst, ok := installedPkgs[k]; ok {
 			installed := inst.Version+"."+inst.Release == sp.RPM.Version+"."+sp.RPM.Release
 			inf.Installed = &installed
 		}
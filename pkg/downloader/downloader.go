@@ -4,18 +4,47 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
 	"sort"
+	"time"
 
-	"github.com/fatih/color"
 	pkgcache "github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/progress"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
 type Result struct {
 	PackagesToBeInstalled   []filespec.FileSpec // contains files that were already cached
 	AuxFilesForInstallation []filespec.FileSpec
+	Plan                    []PlanEntry // sorted by the file name
+}
+
+type Action string
+
+const (
+	ActionInspectFailed    = Action("inspect-failed")
+	ActionNotNeeded        = Action("not-needed")
+	ActionAlreadyInstalled = Action("already-installed")
+	ActionCached           = Action("cached")
+	ActionDownload         = Action("download")
+)
+
+// PlanEntry describes the decision made for a file.
+type PlanEntry struct {
+	Name      string `json:"Name"`
+	Basename  string `json:"Basename"`
+	SHA256    string `json:"SHA256"`
+	Action    Action `json:"Action"`
+	IsPackage bool   `json:"IsPackage,omitempty"`
+	IsAux     bool   `json:"IsAux,omitempty"`
+	Provider  string `json:"Provider,omitempty"` // The first provider to be tried, for ActionDownload
+	URL       string `json:"URL,omitempty"`      // The URL of Provider (redacted)
+	Error     string `json:"Error,omitempty"`    // For ActionInspectFailed
 }
 
 func (r *Result) keep(inf distro.FileInfo) {
@@ -30,6 +59,20 @@ func (r *Result) keep(inf distro.FileInfo) {
 type Opts struct {
 	Providers     []string
 	SkipInstalled bool
+	Parallel      int  // Number of files to be downloaded in parallel. Defaults to 1.
+	DryRun        bool // Only compute Result, without downloading anything. No event is reported.
+	Offline       bool // Only use the cache and file:// providers
+	VerifyCache   bool // Re-hash the cached files, and download them again if they are corrupted
+
+	// Reporter reports the progress.
+	// Defaults to a text reporter that writes to stdout.
+	Reporter progress.Reporter
+
+	// Retries is the number of retries for transient errors, per provider.
+	// The next provider is tried after exhausting the retries.
+	Retries         int
+	RetryBackoff    time.Duration // Initial backoff, doubled on each retry. Defaults to DefaultRetryBackoff.
+	RetryMaxBackoff time.Duration // Defaults to DefaultRetryMaxBackoff.
 }
 
 func Download(ctx context.Context, d distro.Distro, cache *pkgcache.Cache, fileSpecs map[string]*filespec.FileSpec, opts Opts) (*Result, error) {
@@ -48,6 +91,12 @@ func Download(ctx context.Context, d distro.Distro, cache *pkgcache.Cache, fileS
 		return nil, errors.New("provider needs to be specified")
 	}
 
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	retry := newRetryPolicy(opts)
+
 	var fnames []string
 	for f := range fileSpecs {
 		fnames = append(fnames, f)
@@ -55,27 +104,65 @@ func Download(ctx context.Context, d distro.Distro, cache *pkgcache.Cache, fileS
 	sort.Strings(fnames)
 	l := len(fnames)
 
-	markUpProgressCounter := color.New(color.Bold).SprintFunc()
-	markUpPackage := color.New(color.FgCyan).SprintFunc()
-	markUpComment := color.New(color.FgHiBlack).SprintFunc()
-	printPackageStatusBase := func(i int, pkg, s string, ff ...interface{}) {
-		fmt.Println(markUpProgressCounter(fmt.Sprintf("(%03d/%03d)", i+1, l)) + " " + markUpPackage(pkg) + " " + markUpComment(fmt.Sprintf(s, ff...)))
+	reporter := opts.Reporter
+	if reporter == nil {
+		reporter = progress.NewTextReporter(os.Stdout, parallel == 1)
+	}
+	if opts.DryRun {
+		reporter = progress.Nop
 	}
 
-	var res Result
-	for i, fname := range fnames {
-		sp := fileSpecs[fname]
-		printPackageStatus := func(s string, ff ...interface{}) {
-			printPackageStatusBase(i, sp.Basename, s, ff...)
+	if !opts.DryRun {
+		// Protect the files of this run from the eviction
+		for _, sp := range fileSpecs {
+			cache.Pin(sp.SHA256)
+		}
+	}
+
+	plan := make([]PlanEntry, l) // indexed by the position in fnames
+	// download1 returns a non-nil FileInfo when the file has to be kept in the result
+	download1 := func(ctx context.Context, i int, sp *filespec.FileSpec) (*distro.FileInfo, error) {
+		// fileReporter fills the file information of the events
+		fileReporter := progress.ReporterFunc(func(ev progress.Event) {
+			if ev.Time.IsZero() {
+				ev.Time = time.Now()
+			}
+			ev.Index = i + 1
+			ev.Total = l
+			ev.Name = sp.Name
+			ev.Basename = sp.Basename
+			ev.SHA256 = sp.SHA256
+			reporter.Report(ev)
+		})
+		reportInspected := func(status string, err error) {
+			ev := progress.Event{
+				Type:   progress.EventFileInspected,
+				Status: status,
+			}
+			if err != nil {
+				ev.Error = err.Error()
+			}
+			fileReporter.Report(ev)
+		}
+		ent := &plan[i]
+		*ent = PlanEntry{
+			Name:     sp.Name,
+			Basename: sp.Basename,
+			SHA256:   sp.SHA256,
 		}
 		inf, err := d.InspectFile(ctx, *sp, distro.InspectFileOpts{})
 		if err != nil {
 			logrus.WithError(err).Warnf("Failed to inspect %+v", sp)
-			continue
+			reportInspected(progress.StatusInspectFailed, err)
+			ent.Action = ActionInspectFailed
+			ent.Error = err.Error()
+			return nil, nil
 		}
+		ent.IsPackage, ent.IsAux = inf.IsPackage, inf.IsAux
 		if !inf.IsPackage && !inf.IsAux {
-			printPackageStatus("Not needed")
-			continue
+			reportInspected(progress.StatusNotNeeded, nil)
+			ent.Action = ActionNotNeeded
+			return nil, nil
 		}
 		if opts.SkipInstalled {
 			var installed bool
@@ -86,40 +173,131 @@ func Download(ctx context.Context, d distro.Distro, cache *pkgcache.Cache, fileS
 				installed = *infDeep.Installed
 			}
 			if installed {
-				printPackageStatus("Already installed")
-				continue
+				reportInspected(progress.StatusAlreadyInstalled, nil)
+				ent.Action = ActionAlreadyInstalled
+				return nil, nil
 			}
 		}
+		reportInspected(progress.StatusNeeded, nil)
 		cached, err := cache.Cached(sp.SHA256)
 		if err != nil {
 			logrus.WithError(err).Warnf("Failed to check whether %q (%q) is cached", sp.SHA256, sp.Basename)
 			cached = false
 		}
+		if cached && opts.VerifyCache {
+			if err := cache.VerifyBlob(sp.SHA256); err != nil {
+				if !errors.Is(err, pkgcache.ErrCorruptedBlob) {
+					return nil, err
+				}
+				logrus.WithError(err).Warnf("Cached %s is corrupted, downloading again", sp.Basename)
+				cached = false
+				if !opts.DryRun {
+					if err = cache.Quarantine(sp.SHA256); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
 		if cached {
-			printPackageStatus("Cached")
-			res.keep(*inf)
-			continue
+			if !opts.DryRun {
+				if err := cache.Touch(sp.SHA256); err != nil {
+					logrus.WithError(err).Debugf("Failed to record the access time of %s", sp.SHA256)
+				}
+				// The blob may have been cached under another basename
+				m := &pkgcache.Metadata{
+					Basename: sp.Basename,
+				}
+				if err := cache.MergeMetadata(sp.SHA256, nil, m, ""); err != nil {
+					logrus.WithError(err).Warnf("Failed to update the metadata of %s", sp.SHA256)
+				}
+			}
+			fileReporter.Report(progress.Event{Type: progress.EventCacheHit})
+			ent.Action = ActionCached
+			return inf, nil
 		}
-		for j, provider := range providers {
+		ent.Action = ActionDownload
+		var (
+			urls         []*url.URL
+			urlProviders []string
+		)
+		for _, provider := range providers {
 			u, err := sp.URL(provider)
 			if err != nil {
 				return nil, fmt.Errorf("failed to determine the URL of %v with the provider %q: %w", sp, provider, err)
 			}
-			printPackageStatus("Downloading from %s", u.Redacted())
+			if opts.Offline && urlopener.RequiresNetwork(u) {
+				logrus.Debugf("Skipping %s (%s) in the offline mode", sp.Basename, urlopener.Redacted(u))
+				continue
+			}
+			if len(urls) == 0 {
+				ent.Provider = urlopener.RedactedString(provider)
+				ent.URL = urlopener.Redacted(u)
+			}
+			urls = append(urls, u)
+			urlProviders = append(urlProviders, provider)
+		}
+		if len(urls) == 0 {
+			// Only happens in the offline mode
+			err := fmt.Errorf("%s (sha256:%s) is not cached, and no file:// provider is available: %w", sp.Basename, sp.SHA256, urlopener.ErrOffline)
+			if opts.DryRun {
+				ent.Error = err.Error()
+				return inf, nil
+			}
+			return nil, err
+		}
+		if opts.DryRun {
+			return inf, nil
+		}
+		for j, u := range urls {
 			m := &pkgcache.Metadata{
 				Basename: sp.Basename,
 			}
-			if err = cache.Ensure(ctx, u, sp.SHA256, m); err != nil {
-				if j != len(providers)-1 {
-					logrus.WithError(err).Warnf("Failed to download %s (%s), trying the next provider", sp.Basename, u.Redacted())
+			err = retry.do(ctx, fmt.Sprintf("%s (%s)", sp.Basename, urlopener.Redacted(u)), func() error {
+				return cache.Ensure(ctx, u, sp.SHA256, m, pkgcache.WithReporter(fileReporter), pkgcache.WithProvider(urlProviders[j]))
+			})
+			if err != nil {
+				if j != len(urls)-1 {
+					logrus.WithError(err).Warnf("Failed to download %s (%s), trying the next provider", sp.Basename, urlopener.Redacted(u))
+					fileReporter.Report(progress.Event{
+						Type:  progress.EventProviderFailover,
+						URL:   urlopener.Redacted(u),
+						Error: err.Error(),
+					})
 				} else {
-					return nil, fmt.Errorf("failed to download %s (%s): %w", sp.Basename, u.Redacted(), err)
+					return nil, fmt.Errorf("failed to download %s (%s): %w", sp.Basename, urlopener.Redacted(u), err)
 				}
 			} else {
 				break
 			}
 		}
-		res.keep(*inf)
+		return inf, nil
+	}
+
+	kept := make([]*distro.FileInfo, l) // indexed by the position in fnames, for deterministic results
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(parallel)
+	for i, fname := range fnames {
+		i, sp := i, fileSpecs[fname]
+		g.Go(func() error {
+			inf, err := download1(gCtx, i, sp)
+			if err != nil {
+				return err
+			}
+			kept[i] = inf
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	res := Result{
+		Plan: plan,
+	}
+	for _, inf := range kept {
+		if inf != nil {
+			res.keep(*inf)
+		}
 	}
 	return &res, nil
 }
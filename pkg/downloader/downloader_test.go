@@ -0,0 +1,409 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	pkgcache "github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/progress"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"gotest.tools/v3/assert"
+)
+
+// testDistro treats every file as a package
+type testDistro struct {
+	distro.Distro
+}
+
+func (d *testDistro) Info() distro.Info {
+	return distro.Info{Name: "test"}
+}
+
+func (d *testDistro) InspectFile(ctx context.Context, sp filespec.FileSpec, opts distro.InspectFileOpts) (*distro.FileInfo, error) {
+	return &distro.FileInfo{
+		FileSpec:    sp,
+		IsPackage:   true,
+		PackageName: sp.Basename,
+	}, nil
+}
+
+func newTestFileSpecs(t testing.TB, n int) (map[string]*filespec.FileSpec, map[string][]byte) {
+	t.Helper()
+	fileSpecs := make(map[string]*filespec.FileSpec, n)
+	contents := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("files/file-%03d", i)
+		b := []byte("content-" + name)
+		sp, err := filespec.New(name, digest.SHA256.FromBytes(b).Encoded())
+		assert.NilError(t, err)
+		fileSpecs[name] = sp
+		contents[path.Base(name)] = b
+	}
+	return fileSpecs, contents
+}
+
+func newTestHandler(contents map[string][]byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := contents[path.Base(r.URL.Path)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(b)
+	})
+}
+
+func newTestServer(contents map[string][]byte) *httptest.Server {
+	return httptest.NewServer(newTestHandler(contents))
+}
+
+func TestDownloadParallel(t *testing.T) {
+	fileSpecs, contents := newTestFileSpecs(t, 20)
+	srv := newTestServer(contents)
+	defer srv.Close()
+
+	var expected []string
+	for name := range fileSpecs {
+		expected = append(expected, name)
+	}
+	sort.Strings(expected)
+
+	for _, parallel := range []int{0, 1, 4, 32} {
+		t.Run(fmt.Sprintf("parallel=%d", parallel), func(t *testing.T) {
+			cache, err := pkgcache.New(t.TempDir())
+			assert.NilError(t, err)
+			opts := Opts{
+				Providers: []string{srv.URL + "/{{.Name}}"},
+				Parallel:  parallel,
+			}
+			res, err := Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+			assert.NilError(t, err)
+			var got []string
+			for _, sp := range res.PackagesToBeInstalled {
+				got = append(got, sp.Name)
+				cached, err := cache.Cached(sp.SHA256)
+				assert.NilError(t, err)
+				assert.Check(t, cached)
+			}
+			assert.DeepEqual(t, expected, got)
+		})
+	}
+}
+
+// newFlakyTestServer returns a server that fails the first `failures` requests with the status.
+func newFlakyTestServer(contents map[string][]byte, failures int32, status int, hdr http.Header) (*httptest.Server, *int32) {
+	var requests int32
+	inner := newTestHandler(contents)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			for k, v := range hdr {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		inner.ServeHTTP(w, r)
+	}))
+	return srv, &requests
+}
+
+func TestDownloadRetry(t *testing.T) {
+	fileSpecs, contents := newTestFileSpecs(t, 1)
+	type testCase struct {
+		name             string
+		failures         int32
+		status           int
+		hdr              http.Header
+		retries          int
+		expectedErr      bool
+		expectedRequests int32
+	}
+	testCases := []testCase{
+		{name: "NoFailure", retries: 3, expectedRequests: 1},
+		{name: "503", failures: 2, status: http.StatusServiceUnavailable, retries: 3, expectedRequests: 3},
+		{name: "503Exhausted", failures: 3, status: http.StatusServiceUnavailable, retries: 2, expectedErr: true, expectedRequests: 3},
+		{name: "429RetryAfter", failures: 1, status: http.StatusTooManyRequests, hdr: http.Header{"Retry-After": []string{"0"}}, retries: 1, expectedRequests: 2},
+		{name: "404", failures: 1, status: http.StatusNotFound, retries: 3, expectedErr: true, expectedRequests: 1},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			srv, requests := newFlakyTestServer(contents, tc.failures, tc.status, tc.hdr)
+			defer srv.Close()
+			cache, err := pkgcache.New(t.TempDir())
+			assert.NilError(t, err)
+			opts := Opts{
+				Providers:       []string{srv.URL + "/{{.Name}}"},
+				Retries:         tc.retries,
+				RetryBackoff:    time.Millisecond,
+				RetryMaxBackoff: 10 * time.Millisecond,
+			}
+			_, err = Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+			if tc.expectedErr {
+				assert.Check(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.Equal(t, tc.expectedRequests, atomic.LoadInt32(requests))
+		})
+	}
+}
+
+func TestDownloadRetryFailover(t *testing.T) {
+	fileSpecs, contents := newTestFileSpecs(t, 1)
+	broken, brokenRequests := newFlakyTestServer(contents, 100, http.StatusBadGateway, nil)
+	defer broken.Close()
+	srv := newTestServer(contents)
+	defer srv.Close()
+	cache, err := pkgcache.New(t.TempDir())
+	assert.NilError(t, err)
+	opts := Opts{
+		Providers:    []string{broken.URL + "/{{.Name}}", srv.URL + "/{{.Name}}"},
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+	}
+	_, err = Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(brokenRequests))
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := newRetryPolicy(Opts{RetryBackoff: time.Second, RetryMaxBackoff: 4 * time.Second})
+	for attempt, expectedMax := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		d := p.delay(attempt, nil)
+		assert.Check(t, d >= expectedMax/2 && d <= expectedMax, "attempt=%d, delay=%v", attempt, d)
+	}
+	statusErr := &urlopener.HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}
+	assert.Equal(t, 3*time.Second, p.delay(0, statusErr))
+	statusErr.RetryAfter = 24 * time.Hour
+	assert.Equal(t, 4*time.Second, p.delay(0, statusErr), "Retry-After must be clamped to the max backoff")
+}
+
+func TestDownloadDryRun(t *testing.T) {
+	fileSpecs, contents := newTestFileSpecs(t, 3)
+	srv, requests := newFlakyTestServer(contents, 0, 0, nil)
+	defer srv.Close()
+	cache, err := pkgcache.New(t.TempDir())
+	assert.NilError(t, err)
+	cachedSpec := fileSpecs["files/file-001"]
+	cachedURL, err := cachedSpec.URL(srv.URL + "/{{.Name}}")
+	assert.NilError(t, err)
+	assert.NilError(t, cache.Ensure(context.TODO(), cachedURL, cachedSpec.SHA256, nil))
+	atomic.StoreInt32(requests, 0)
+
+	provider := srv.URL + "/{{.Name}}"
+	opts := Opts{
+		Providers: []string{provider},
+		DryRun:    true,
+	}
+	res, err := Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(requests))
+	assert.Equal(t, 3, len(res.PackagesToBeInstalled))
+	assert.Equal(t, 3, len(res.Plan))
+	for _, ent := range res.Plan {
+		sp := fileSpecs[ent.Name]
+		assert.Equal(t, sp.SHA256, ent.SHA256)
+		assert.Check(t, ent.IsPackage)
+		if sp == cachedSpec {
+			assert.Equal(t, ActionCached, ent.Action)
+			continue
+		}
+		assert.Equal(t, ActionDownload, ent.Action)
+		assert.Equal(t, provider, ent.Provider)
+		assert.Equal(t, srv.URL+"/"+sp.Name, ent.URL)
+		cached, err := cache.Cached(sp.SHA256)
+		assert.NilError(t, err)
+		assert.Check(t, !cached)
+	}
+}
+
+func TestDownloadEvents(t *testing.T) {
+	fileSpecs, contents := newTestFileSpecs(t, 2)
+	srv := newTestServer(contents)
+	defer srv.Close()
+	cache, err := pkgcache.New(t.TempDir())
+	assert.NilError(t, err)
+	var (
+		mu     sync.Mutex
+		events = make(map[string][]progress.EventType) // key: file name
+	)
+	reporter := progress.ReporterFunc(func(ev progress.Event) {
+		if ev.Type == progress.EventDownloadProgress {
+			return
+		}
+		assert.Check(t, !ev.Time.IsZero())
+		assert.Equal(t, 2, ev.Total)
+		assert.Equal(t, fileSpecs[ev.Name].SHA256, ev.SHA256)
+		mu.Lock()
+		events[ev.Name] = append(events[ev.Name], ev.Type)
+		mu.Unlock()
+	})
+	opts := Opts{
+		Providers: []string{srv.URL + "/{{.Name}}"},
+		Parallel:  2,
+		Reporter:  reporter,
+	}
+	for i := 0; i < 2; i++ {
+		_, err = Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+		assert.NilError(t, err)
+	}
+	expected := []progress.EventType{
+		progress.EventFileInspected, progress.EventDownloadStarted, progress.EventDownloadFinished, // 1st run
+		progress.EventFileInspected, progress.EventCacheHit, // 2nd run
+	}
+	for name := range fileSpecs {
+		assert.DeepEqual(t, expected, events[name])
+	}
+}
+
+func TestDownloadOffline(t *testing.T) {
+	fileSpecs, contents := newTestFileSpecs(t, 3)
+	srv, requests := newFlakyTestServer(contents, 0, 0, nil)
+	defer srv.Close()
+	fileDir := t.TempDir()
+	for basename, b := range contents {
+		assert.NilError(t, os.WriteFile(filepath.Join(fileDir, basename), b, 0644))
+	}
+	httpProvider := srv.URL + "/{{.Name}}"
+	fileProvider := "file://" + fileDir + "/{{.Basename}}"
+	newOfflineCache := func() *pkgcache.Cache {
+		cache, err := pkgcache.New(t.TempDir(), pkgcache.WithURLOpener(urlopener.New(urlopener.WithOffline(true))))
+		assert.NilError(t, err)
+		return cache
+	}
+
+	t.Run("FileProvider", func(t *testing.T) {
+		cache := newOfflineCache()
+		opts := Opts{
+			Providers: []string{httpProvider, fileProvider},
+			Offline:   true,
+			Reporter:  progress.Nop,
+		}
+		res, err := Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+		assert.NilError(t, err)
+		assert.Equal(t, 3, len(res.PackagesToBeInstalled))
+		assert.Equal(t, int32(0), atomic.LoadInt32(requests))
+		for _, ent := range res.Plan {
+			assert.Equal(t, fileProvider, ent.Provider)
+		}
+	})
+
+	t.Run("NoFileProvider", func(t *testing.T) {
+		cache := newOfflineCache()
+		opts := Opts{
+			Providers: []string{httpProvider},
+			Offline:   true,
+			Reporter:  progress.Nop,
+		}
+		_, err := Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+		assert.Check(t, errors.Is(err, urlopener.ErrOffline), err)
+		sp := fileSpecs["files/file-000"]
+		assert.ErrorContains(t, err, sp.Basename)
+		assert.ErrorContains(t, err, sp.SHA256)
+		assert.Equal(t, int32(0), atomic.LoadInt32(requests))
+
+		// The URL opener of the cache refuses the network access too
+		u, err := sp.URL(httpProvider)
+		assert.NilError(t, err)
+		err = cache.Ensure(context.TODO(), u, sp.SHA256, nil)
+		assert.Check(t, errors.Is(err, urlopener.ErrOffline), err)
+		assert.Equal(t, int32(0), atomic.LoadInt32(requests))
+	})
+}
+
+func TestDownloadVerifyCache(t *testing.T) {
+	fileSpecs, contents := newTestFileSpecs(t, 3)
+	srv, requests := newFlakyTestServer(contents, 0, 0, nil)
+	defer srv.Close()
+	cache, err := pkgcache.New(t.TempDir())
+	assert.NilError(t, err)
+	opts := Opts{
+		Providers: []string{srv.URL + "/{{.Name}}"},
+		Reporter:  progress.Nop,
+	}
+	_, err = Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+
+	sp := fileSpecs["files/file-001"]
+	blob, err := cache.BlobAbsPath(sp.SHA256)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(blob, []byte("corrupted"), 0644))
+
+	// Without VerifyCache, the corrupted blob is trusted
+	_, err = Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+
+	opts.VerifyCache = true
+	res, err := Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(requests))
+	assert.Equal(t, 3, len(res.PackagesToBeInstalled))
+	assert.NilError(t, cache.VerifyBlob(sp.SHA256))
+}
+
+func TestDownloadCacheHitRecordsBasename(t *testing.T) {
+	fileSpecs, contents := newTestFileSpecs(t, 1)
+	sp := fileSpecs["files/file-000"]
+	renamed, err := filespec.New("renamed/file-000-renamed", sp.SHA256)
+	assert.NilError(t, err)
+	contents[renamed.Basename] = contents[sp.Basename]
+	srv, requests := newFlakyTestServer(contents, 0, 0, nil)
+	defer srv.Close()
+	cache, err := pkgcache.New(t.TempDir())
+	assert.NilError(t, err)
+	opts := Opts{
+		Providers: []string{srv.URL + "/{{.Name}}"},
+		Reporter:  progress.Nop,
+	}
+	_, err = Download(context.TODO(), &testDistro{}, cache, fileSpecs, opts)
+	assert.NilError(t, err)
+	// Cache hit under another name
+	_, err = Download(context.TODO(), &testDistro{}, cache, map[string]*filespec.FileSpec{renamed.Name: renamed}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	m, err := cache.MetadataBySHA256(sp.SHA256)
+	assert.NilError(t, err)
+	assert.Equal(t, sp.Basename, m.Basename)
+	assert.DeepEqual(t, []string{sp.Basename, renamed.Basename}, m.Basenames)
+	assert.Check(t, m.FirstFetched != nil)
+
+	t.Run("Version0", func(t *testing.T) {
+		// Metadata written by the versions of repro-get prior to the metadata version 1
+		metadataFile, err := cache.MetadataFileAbsPath(sp.SHA256)
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(metadataFile, []byte(`{"Basename":"`+sp.Basename+`"}`), 0644))
+		_, err = Download(context.TODO(), &testDistro{}, cache, map[string]*filespec.FileSpec{renamed.Name: renamed}, opts)
+		assert.NilError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+		m, err := cache.MetadataBySHA256(sp.SHA256)
+		assert.NilError(t, err)
+		assert.Equal(t, pkgcache.MetadataVersion, m.Version)
+		assert.Equal(t, sp.Basename, m.Basename)
+		assert.DeepEqual(t, []string{sp.Basename, renamed.Basename}, m.Basenames)
+		// The time of the first fetch is unknown, and must not be the time of the cache hit
+		assert.Check(t, m.FirstFetched == nil)
+		b, err := os.ReadFile(metadataFile)
+		assert.NilError(t, err)
+		assert.Check(t, !strings.Contains(string(b), "FirstFetched"), string(b))
+	})
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultRetryBackoff    = time.Second
+	DefaultRetryMaxBackoff = 30 * time.Second
+)
+
+type retryPolicy struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func newRetryPolicy(opts Opts) retryPolicy {
+	p := retryPolicy{
+		retries:    opts.Retries,
+		backoff:    opts.RetryBackoff,
+		maxBackoff: opts.RetryMaxBackoff,
+	}
+	if p.retries < 0 {
+		p.retries = 0
+	}
+	if p.backoff <= 0 {
+		p.backoff = DefaultRetryBackoff
+	}
+	if p.maxBackoff <= 0 {
+		p.maxBackoff = DefaultRetryMaxBackoff
+	}
+	if p.maxBackoff < p.backoff {
+		p.maxBackoff = p.backoff
+	}
+	return p
+}
+
+// delay returns the delay before the retry #(attempt+1).
+// The Retry-After header is honored if present (up to maxBackoff), otherwise the delay is
+// an exponential backoff with jitter.
+func (p retryPolicy) delay(attempt int, err error) time.Duration {
+	if d, ok := urlopener.RetryAfter(err); ok {
+		if d > p.maxBackoff {
+			// Do not let a server stall the download, e.g., with "Retry-After: 86400"
+			d = p.maxBackoff
+		}
+		return d
+	}
+	d := p.backoff
+	for i := 0; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	// "Equal jitter": [d/2, d)
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// do calls f until it succeeds, returns a non-transient error, or exhausts the retries.
+func (p retryPolicy) do(ctx context.Context, desc string, f func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= p.retries || !urlopener.IsTransient(err) {
+			return err
+		}
+		d := p.delay(attempt, err)
+		logrus.WithError(err).Warnf("Failed to download %s, retrying in %v (%d/%d)", desc, d.Round(time.Millisecond), attempt+1, p.retries)
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+
+	"pault.ag/go/debian/version"
 )
 
 type Dpkg struct {
@@ -31,3 +33,31 @@ func Split(trimmed string) (*Dpkg, error) {
 		Architecture: sp[2],
 	}, nil
 }
+
+// CompareVersions compares the Debian version strings, and returns -1, 0, or 1.
+// The epoch may be URL-escaped as in the pool file names ("1%3a2.3-4").
+func CompareVersions(a, b string) (int, error) {
+	aV, err := version.Parse(unescapeEpoch(a))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse version %q: %w", a, err)
+	}
+	bV, err := version.Parse(unescapeEpoch(b))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse version %q: %w", b, err)
+	}
+	switch c := version.Compare(aV, bV); {
+	case c < 0:
+		return -1, nil
+	case c > 0:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+func unescapeEpoch(s string) string {
+	if i := strings.Index(strings.ToLower(s), "%3a"); i >= 0 {
+		return s[:i] + ":" + s[i+len("%3a"):]
+	}
+	return s
+}
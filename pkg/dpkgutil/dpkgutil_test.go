@@ -25,3 +25,23 @@ func TestParseFilename(t *testing.T) {
 	}
 	assert.DeepEqual(t, expected, got)
 }
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"2.10-2", "2.10-2", 0},
+		{"2.10-2", "2.10-3", -1},
+		{"2.10-10", "2.10-9", 1},
+		{"2.10~rc1-1", "2.10-1", -1},
+		{"1%3a1.0-1", "2.0-1", 1},
+		{"1:1.0-1", "1%3a1.0-1", 0},
+		{"1.0+deb12u1", "1.0", 1},
+	}
+	for _, tc := range testCases {
+		got, err := CompareVersions(tc.a, tc.b)
+		assert.NilError(t, err)
+		assert.Equal(t, tc.expected, got, "%q vs %q", tc.a, tc.b)
+	}
+}
@@ -0,0 +1,110 @@
+// Package filelink creates a file from another file, by copying, hardlinking, reflinking, or symlinking.
+//
+// When the specified mode fails (e.g., reflinks are not supported by the filesystem),
+// the next mode is tried in the following order:
+//
+//	symlink -> hardlink -> reflink -> copy
+package filelink
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/continuity/fs"
+	"github.com/sirupsen/logrus"
+)
+
+// Mode is a link mode.
+type Mode string
+
+const (
+	// ModeCopy copies the file.
+	ModeCopy = Mode("copy")
+
+	// ModeHardlink creates a hard link.
+	// Modifying the destination modifies the source too.
+	ModeHardlink = Mode("hardlink")
+
+	// ModeReflink creates a copy-on-write clone (FICLONE), on the filesystems that support it (e.g., Btrfs, XFS).
+	ModeReflink = Mode("reflink")
+
+	// ModeSymlink creates a symbolic link with the absolute path of the source.
+	ModeSymlink = Mode("symlink")
+)
+
+// ErrUnsupported is returned when the mode is not supported on the platform.
+var ErrUnsupported = errors.New("unsupported")
+
+// Modes returns the list of the link modes.
+func Modes() []Mode {
+	return []Mode{ModeCopy, ModeHardlink, ModeReflink, ModeSymlink}
+}
+
+// ParseMode parses the link mode.
+func ParseMode(s string) (Mode, error) {
+	for _, f := range Modes() {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown link mode %q (valid values: %v)", s, Modes())
+}
+
+// fallback returns the mode to be tried when m fails, or an empty string.
+func (m Mode) fallback() Mode {
+	switch m {
+	case ModeSymlink:
+		return ModeHardlink
+	case ModeHardlink:
+		return ModeReflink
+	case ModeReflink:
+		return ModeCopy
+	default:
+		return ""
+	}
+}
+
+// Link creates dst from src with the mode, and returns the mode that was actually used.
+// When the mode fails, the next mode is tried (see the package document).
+// dst must not exist.
+func Link(dst, src string, mode Mode) (Mode, error) {
+	if _, err := ParseMode(string(mode)); err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(dst); !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("avoiding to overwrite existing file %q", dst)
+	}
+	for {
+		err := link1(dst, src, mode)
+		if err == nil {
+			return mode, nil
+		}
+		next := mode.fallback()
+		if next == "" {
+			return "", err
+		}
+		logrus.WithError(err).Debugf("Failed to create %q with the link mode %q, falling back to %q", dst, mode, next)
+		mode = next
+	}
+}
+
+func link1(dst, src string, mode Mode) error {
+	switch mode {
+	case ModeCopy:
+		return fs.CopyFile(dst, src)
+	case ModeHardlink:
+		return os.Link(src, dst)
+	case ModeReflink:
+		return reflink(dst, src)
+	case ModeSymlink:
+		srcAbs, err := filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(srcAbs, dst)
+	default:
+		return fmt.Errorf("unknown link mode %q", mode)
+	}
+}
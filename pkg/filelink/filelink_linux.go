@@ -0,0 +1,29 @@
+package filelink
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func reflink(dst, src string) error {
+	srcF, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcF.Close()
+	st, err := srcF.Stat()
+	if err != nil {
+		return err
+	}
+	dstF, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, st.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if err = unix.IoctlFileClone(int(dstF.Fd()), int(srcF.Fd())); err != nil {
+		dstF.Close()
+		os.Remove(dst)
+		return &os.PathError{Op: "ioctl FICLONE", Path: dst, Err: err}
+	}
+	return dstF.Close()
+}
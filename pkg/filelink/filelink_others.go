@@ -0,0 +1,11 @@
+//go:build !linux
+
+package filelink
+
+import (
+	"fmt"
+)
+
+func reflink(dst, src string) error {
+	return fmt.Errorf("reflink: %w", ErrUnsupported)
+}
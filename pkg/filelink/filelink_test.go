@@ -0,0 +1,56 @@
+package filelink
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLink(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	assert.NilError(t, os.WriteFile(src, []byte("hello"), 0644))
+	srcSt, err := os.Stat(src)
+	assert.NilError(t, err)
+
+	for _, mode := range Modes() {
+		mode := mode
+		t.Run(string(mode), func(t *testing.T) {
+			dst := filepath.Join(dir, "dst-"+string(mode))
+			used, err := Link(dst, src, mode)
+			assert.NilError(t, err)
+			b, err := os.ReadFile(dst)
+			assert.NilError(t, err)
+			assert.Equal(t, "hello", string(b))
+			dstSt, err := os.Stat(dst)
+			assert.NilError(t, err)
+			switch mode {
+			case ModeReflink:
+				// Falls back to copy on tmpfs, ext4, etc.
+				assert.Check(t, used == ModeReflink || used == ModeCopy, used)
+				assert.Check(t, !os.SameFile(srcSt, dstSt))
+			default:
+				assert.Equal(t, mode, used)
+				assert.Equal(t, mode == ModeHardlink || mode == ModeSymlink, os.SameFile(srcSt, dstSt))
+			}
+
+			_, err = Link(dst, src, mode)
+			assert.ErrorContains(t, err, "avoiding to overwrite")
+		})
+	}
+
+	_, err = ParseMode("foo")
+	assert.ErrorContains(t, err, "unknown link mode")
+}
+
+func TestLinkFallback(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "dst")
+	// hardlink, reflink, and copy fail for a non-existent source
+	_, err := Link(dst, filepath.Join(dir, "non-existent"), ModeHardlink)
+	assert.Check(t, err != nil)
+	_, err = os.Lstat(dst)
+	assert.Check(t, os.IsNotExist(err), "no partial file should be left")
+}
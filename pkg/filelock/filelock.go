@@ -0,0 +1,62 @@
+// Package filelock provides advisory file locks that work across processes.
+//
+// The locks are released when the process exits.
+// On the platforms without flock(2), the locks only work within the process.
+package filelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrWouldBlock is returned by TryExclusive when the lock is held by others.
+var ErrWouldBlock = errors.New("the lock is held by others")
+
+// Lock is an acquired lock.
+type Lock struct {
+	f *os.File
+}
+
+// Shared acquires a shared lock on the file.
+// The file and its parent directories are created if they do not exist.
+// Blocks until the lock is acquired.
+func Shared(file string) (*Lock, error) {
+	return acquire(file, false, true)
+}
+
+// Exclusive acquires an exclusive lock on the file.
+// The file and its parent directories are created if they do not exist.
+// Blocks until the lock is acquired.
+func Exclusive(file string) (*Lock, error) {
+	return acquire(file, true, true)
+}
+
+// TryExclusive is similar to Exclusive, but returns ErrWouldBlock instead of blocking.
+func TryExclusive(file string) (*Lock, error) {
+	return acquire(file, true, false)
+}
+
+func acquire(file string, exclusive, blocking bool) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = lock(f, exclusive, blocking); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	if err := unlock(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,54 @@
+//go:build !unix || aix
+
+package filelock
+
+import (
+	"os"
+	"sync"
+)
+
+// Without flock(2), the locks are emulated with in-process mutexes, keyed by the file name.
+var (
+	mutexesMu sync.Mutex
+	mutexes   = make(map[string]*sync.RWMutex)
+	held      = make(map[*os.File]func())
+)
+
+func lock(f *os.File, exclusive, blocking bool) error {
+	mutexesMu.Lock()
+	mu, ok := mutexes[f.Name()]
+	if !ok {
+		mu = &sync.RWMutex{}
+		mutexes[f.Name()] = mu
+	}
+	mutexesMu.Unlock()
+	var unlockFn func()
+	switch {
+	case exclusive && blocking:
+		mu.Lock()
+		unlockFn = mu.Unlock
+	case exclusive:
+		if !mu.TryLock() {
+			return ErrWouldBlock
+		}
+		unlockFn = mu.Unlock
+	default:
+		mu.RLock()
+		unlockFn = mu.RUnlock
+	}
+	mutexesMu.Lock()
+	held[f] = unlockFn
+	mutexesMu.Unlock()
+	return nil
+}
+
+func unlock(f *os.File) error {
+	mutexesMu.Lock()
+	unlockFn := held[f]
+	delete(held, f)
+	mutexesMu.Unlock()
+	if unlockFn != nil {
+		unlockFn()
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package filelock
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sub", "lock")
+
+	shared1, err := Shared(file)
+	assert.NilError(t, err)
+	shared2, err := Shared(file)
+	assert.NilError(t, err)
+	_, err = TryExclusive(file)
+	assert.Check(t, errors.Is(err, ErrWouldBlock), err)
+	assert.NilError(t, shared1.Unlock())
+	assert.NilError(t, shared2.Unlock())
+
+	exclusive, err := TryExclusive(file)
+	assert.NilError(t, err)
+	_, err = TryExclusive(file)
+	assert.Check(t, errors.Is(err, ErrWouldBlock), err)
+
+	acquired := make(chan struct{})
+	go func() {
+		l, err := Shared(file)
+		assert.Check(t, err)
+		close(acquired)
+		assert.Check(t, l.Unlock())
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the shared lock must not be acquired while the exclusive lock is held")
+	default:
+	}
+	assert.NilError(t, exclusive.Unlock())
+	<-acquired
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/opencontainers/go-digest"
 	"github.com/reproducible-containers/repro-get/pkg/apkutil"
 	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
 	"github.com/reproducible-containers/repro-get/pkg/pacmanutil"
 	"github.com/reproducible-containers/repro-get/pkg/rpmutil"
 	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
@@ -42,8 +43,10 @@ func ValidateName(name string) error {
 }
 
 type opts struct {
-	cid   string
-	epoch *time.Time
+	cid    string
+	ociRef string
+	epoch  *time.Time
+	size   int64
 }
 
 type Option func(o *opts)
@@ -54,12 +57,24 @@ func WithCID(cid string) Option {
 	}
 }
 
+func WithOCIRef(ociRef string) Option {
+	return func(o *opts) {
+		o.ociRef = ociRef
+	}
+}
+
 func WithEpoch(epoch *time.Time) Option {
 	return func(o *opts) {
 		o.epoch = epoch
 	}
 }
 
+func WithSize(size int64) Option {
+	return func(o *opts) {
+		o.size = size
+	}
+}
+
 func New(name, sha256 string, options ...Option) (*FileSpec, error) {
 	var opts opts
 	for _, o := range options {
@@ -76,7 +91,9 @@ func New(name, sha256 string, options ...Option) (*FileSpec, error) {
 		Basename: filepath.Base(name),
 		SHA256:   sha256,
 		CID:      opts.cid,
+		OCIRef:   opts.ociRef,
 		Epoch:    opts.epoch,
+		Size:     opts.size,
 	}
 	switch {
 	case strings.HasSuffix(name, ".deb"):
@@ -108,11 +125,13 @@ func New(name, sha256 string, options ...Option) (*FileSpec, error) {
 }
 
 type FileSpec struct {
-	Name     string             `json:"Name"`            // "pool/main/h/hello/hello_2.10-2_amd64.deb"
-	Basename string             `json:"Basename"`        // "hello_2.10-2_amd64.deb"
-	SHA256   string             `json:"SHA256"`          // "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
-	CID      string             `json:"CID,omitempty"`   // IPFS CID
-	Epoch    *time.Time         `json:"Epoch,omitempty"` // Timestamp of SHA256SUMS, or $SOURCE_DATE_EPOCH
+	Name     string             `json:"Name"`             // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	Basename string             `json:"Basename"`         // "hello_2.10-2_amd64.deb"
+	SHA256   string             `json:"SHA256"`           // "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
+	CID      string             `json:"CID,omitempty"`    // IPFS CID
+	OCIRef   string             `json:"OCIRef,omitempty"` // OCI repository that contains the blob, e.g., "ghcr.io/USERNAME/dpkgs"
+	Epoch    *time.Time         `json:"Epoch,omitempty"`  // Timestamp of SHA256SUMS (or the epoch in the lockfile), or $SOURCE_DATE_EPOCH
+	Size     int64              `json:"Size,omitempty"`   // Only known with a lockfile
 	Dpkg     *dpkgutil.Dpkg     `json:"Dpkg,omitempty"`
 	RPM      *rpmutil.RPM       `json:"RPM,omitempty"`
 	APK      *apkutil.APK       `json:"APK,omitempty"`
@@ -142,6 +161,9 @@ func (sp FileSpec) URL(provider string) (*url.URL, error) {
 	if strings.Contains(provider, ".CID") && sp.CID == "" {
 		return nil, fmt.Errorf("no CID is known for sha256 %q", sp.SHA256)
 	}
+	if strings.Contains(provider, ".OCIRef") && sp.OCIRef == "" {
+		return nil, fmt.Errorf("no OCI ref is known for sha256 %q", sp.SHA256)
+	}
 
 	tmpl, err := template.New("").Funcs(FileSpecTemplateFuncMap).Parse(provider)
 	if err != nil {
@@ -172,14 +194,25 @@ func (sp FileSpec) URL(provider string) (*url.URL, error) {
 	return u, nil
 }
 
-// PseudoFilename is prefixed with "/ipfs/".
-// e.g., "/ipfs/QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH".
+// PseudoFilename is prefixed with "/ipfs/" or "/oci/".
+// e.g., "/ipfs/QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH", "/oci/ghcr.io/USERNAME/dpkgs".
 type PseudoFilename struct {
-	CID string
+	CID    string
+	OCIRef string
 }
 
 // ParsePseudoFilename parses a pseudo file name.
 func ParsePseudoFilename(s string) *PseudoFilename {
+	if strings.HasPrefix(s, "/oci/") {
+		ociRef := strings.TrimPrefix(s, "/oci/")
+		if ociRef == "" || strings.Contains(ociRef, "://") {
+			logrus.Warnf("Invalid pseudo OCI filename: expected \"/oci/<REF>\", got %q", s)
+			return nil
+		}
+		return &PseudoFilename{
+			OCIRef: ociRef,
+		}
+	}
 	if !strings.HasPrefix(s, "/ipfs/") {
 		return nil
 	}
@@ -207,7 +240,7 @@ func WithHashMapEpoch(epoch *time.Time) HashMapOption {
 
 // NewFromSHA256SUMS returns a file spec map from the sha256sums map.
 // The key of the returned map is a file name such as "pool/main/h/hello/hello_2.10-2_amd64.deb"".
-// The key does not contain "pseudo" file names prefixed with "/ipfs/".
+// The key does not contain "pseudo" file names prefixed with "/ipfs/" or "/oci/".
 func NewFromSHA256SUMS(sha256sumsMapByFilename map[string]string, options ...HashMapOption) (map[string]*FileSpec, error) {
 	var opts hashMapOpts
 	for _, o := range options {
@@ -220,10 +253,18 @@ func NewFromSHA256SUMS(sha256sumsMapByFilename map[string]string, options ...Has
 	}
 	sort.Strings(allFilenames)
 	entries := make(map[string]*FileSpec)
-	cids := make(map[string]string) // key: sha256, value: cid
+	cids := make(map[string]string)    // key: sha256, value: cid
+	ociRefs := make(map[string]string) // key: sha256, value: OCI ref
 	for _, filenameMaybePseudo := range allFilenames {
 		sum := sha256sumsMapByFilename[filenameMaybePseudo]
 		if pseudo := ParsePseudoFilename(filenameMaybePseudo); pseudo != nil {
+			if pseudo.OCIRef != "" {
+				if oldOCIRef := ociRefs[sum]; oldOCIRef != "" {
+					logrus.Warnf("Multiple OCI refs found for SHA256 %q, discarding OCI ref %q, using %q", sum, oldOCIRef, pseudo.OCIRef)
+				}
+				ociRefs[sum] = pseudo.OCIRef
+				continue
+			}
 			if oldCID := cids[sum]; oldCID != "" {
 				logrus.Warnf("Multiple CIDs found for SHA256 %q, discarding CID %q, using %q", sum, oldCID, pseudo.CID)
 			}
@@ -231,8 +272,9 @@ func NewFromSHA256SUMS(sha256sumsMapByFilename map[string]string, options ...Has
 			continue
 		}
 		filename := filenameMaybePseudo
-		cid := cids[sum] // often empty
-		sp, err := New(filename, sum, WithCID(cid), WithEpoch(opts.epoch))
+		cid := cids[sum]       // often empty
+		ociRef := ociRefs[sum] // often empty
+		sp, err := New(filename, sum, WithCID(cid), WithOCIRef(ociRef), WithEpoch(opts.epoch))
 		if err != nil {
 			return nil, err
 		}
@@ -241,6 +283,140 @@ func NewFromSHA256SUMS(sha256sumsMapByFilename map[string]string, options ...Has
 	return entries, nil
 }
 
+// NewFromLockfile returns a file spec map from the lockfile.
+// The package identities in the lockfile are used instead of the ones parsed from the file names.
+// The epoch in the lockfile is used unless WithHashMapEpoch is specified.
+func NewFromLockfile(l *lockfile.Lockfile, options ...HashMapOption) (map[string]*FileSpec, error) {
+	opts := hashMapOpts{
+		epoch: l.Epoch,
+	}
+	for _, o := range options {
+		o(&opts)
+	}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]*FileSpec, len(l.Files))
+	for _, f := range l.Files {
+		sp, err := New(f.Name, f.SHA256, WithCID(f.CID), WithOCIRef(f.OCIRef), WithEpoch(opts.epoch), WithSize(f.Size))
+		hasIdentity := f.Dpkg != nil || f.RPM != nil || f.APK != nil || f.Pacman != nil
+		if err != nil && (sp == nil || !hasIdentity) {
+			return nil, err
+		}
+		if hasIdentity {
+			sp.Dpkg, sp.RPM, sp.APK, sp.Pacman = f.Dpkg, f.RPM, f.APK, f.Pacman
+		}
+		entries[f.Name] = sp
+	}
+	return entries, nil
+}
+
+// NewLockfile returns a lockfile from the file spec map.
+// The epoch and the distro are not set.
+func NewLockfile(fileSpecs map[string]*FileSpec) *lockfile.Lockfile {
+	l := &lockfile.Lockfile{
+		Version: lockfile.Version,
+		Files:   make([]lockfile.File, 0, len(fileSpecs)),
+	}
+	for _, sp := range fileSpecs {
+		l.Files = append(l.Files, lockfile.File{
+			Name:   sp.Name,
+			SHA256: sp.SHA256,
+			Size:   sp.Size,
+			CID:    sp.CID,
+			OCIRef: sp.OCIRef,
+			Dpkg:   sp.Dpkg,
+			RPM:    sp.RPM,
+			APK:    sp.APK,
+			Pacman: sp.Pacman,
+		})
+	}
+	l.Sort()
+	return l
+}
+
+// NewFromFiles is similar to NewFromSHA256SUMSFiles, but accepts lockfiles too (see package lockfile).
+// The format is detected from the content of each file.
+func NewFromFiles(fnames ...string) (map[string]*FileSpec, error) {
+	var sourceDateEpoch *time.Time
+	if v, err := pkgepoch.SourceDateEpoch(); err == nil {
+		sourceDateEpoch = v
+	}
+	res := make(map[string]*FileSpec)
+	for _, fname := range fnames {
+		subRes, err := newFromFile(fname, sourceDateEpoch)
+		if err != nil {
+			return res, fmt.Errorf("failed to parse %q: %w", fname, err)
+		}
+		for k, v := range subRes {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+func newFromFile(fname string, sourceDateEpoch *time.Time) (map[string]*FileSpec, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return newFromBytes(b, sourceDateEpoch, func() (*time.Time, error) { return mtime(fname) })
+}
+
+// NewFromBytes is similar to NewFromFiles, but parses the content of a hash file that has already been read,
+// e.g., so that the content with the verified signature is parsed without reading the file again.
+// modTime is the modification time of the file, used as the epoch unless $SOURCE_DATE_EPOCH is set
+// or the content is a lockfile with the epoch.
+func NewFromBytes(b []byte, modTime time.Time) (map[string]*FileSpec, error) {
+	var sourceDateEpoch *time.Time
+	if v, err := pkgepoch.SourceDateEpoch(); err == nil {
+		sourceDateEpoch = v
+	}
+	return newFromBytes(b, sourceDateEpoch, func() (*time.Time, error) {
+		tm := modTime.UTC()
+		return &tm, nil
+	})
+}
+
+func newFromBytes(b []byte, sourceDateEpoch *time.Time, modTime func() (*time.Time, error)) (map[string]*FileSpec, error) {
+	var err error
+	epoch := sourceDateEpoch
+	if !lockfile.IsLockfile(b) {
+		sums, err := sha256sums.Parse(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse as SHA256SUMS: %w", err)
+		}
+		if epoch == nil {
+			if epoch, err = modTime(); err != nil {
+				return nil, err
+			}
+		}
+		return NewFromSHA256SUMS(sums, WithHashMapEpoch(epoch))
+	}
+	l, err := lockfile.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse as a lockfile: %w", err)
+	}
+	if epoch == nil {
+		epoch = l.Epoch
+	}
+	if epoch == nil {
+		if epoch, err = modTime(); err != nil {
+			return nil, err
+		}
+	}
+	return NewFromLockfile(l, WithHashMapEpoch(epoch))
+}
+
+func mtime(fname string) (*time.Time, error) {
+	st, err := os.Stat(fname) // follow symlinks
+	if err != nil {
+		return nil, err
+	}
+	tm := st.ModTime().UTC()
+	return &tm, nil
+}
+
 func NewFromSHA256SUMSFiles(fnames ...string) (map[string]*FileSpec, error) {
 	var sourceDateEpoch *time.Time
 	if v, err := pkgepoch.SourceDateEpoch(); err == nil {
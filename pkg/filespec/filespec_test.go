@@ -1,8 +1,12 @@
 package filespec
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
 	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
@@ -75,6 +79,28 @@ func TestNewFromSHA256SUMS(t *testing.T) {
 				},
 			},
 		},
+		{
+			sums: `
+# With OCI ref
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  /ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACU
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  /oci/ghcr.io/USERNAME/dpkgs
+`,
+			expected: map[string]*FileSpec{
+				"pool/main/h/hello/hello_2.10-2_amd64.deb": &FileSpec{
+					Name:     "pool/main/h/hello/hello_2.10-2_amd64.deb",
+					Basename: "hello_2.10-2_amd64.deb",
+					SHA256:   "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+					CID:      "QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACU",
+					OCIRef:   "ghcr.io/USERNAME/dpkgs",
+					Dpkg: &dpkgutil.Dpkg{
+						Package:      "hello",
+						Version:      "2.10-2",
+						Architecture: "amd64",
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -85,3 +111,54 @@ func TestNewFromSHA256SUMS(t *testing.T) {
 		assert.DeepEqual(t, tc.expected, got)
 	}
 }
+
+func TestNewFromFiles(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	dir := t.TempDir()
+	sumsFile := filepath.Join(dir, "SHA256SUMS")
+	sums := "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb\n" +
+		"35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  /ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACU\n"
+	assert.NilError(t, os.WriteFile(sumsFile, []byte(sums), 0644))
+	fromSums, err := NewFromFiles(sumsFile)
+	assert.NilError(t, err)
+
+	l := NewLockfile(fromSums)
+	epoch := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.Epoch = &epoch
+	l.Files[0].Size = 56132
+	// The package identity in the lockfile takes precedence over the file name
+	l.Files[0].Dpkg = &dpkgutil.Dpkg{Package: "hello", Version: "2.10-2+local1", Architecture: "amd64"}
+	var b bytes.Buffer
+	assert.NilError(t, l.Write(&b))
+	lockFile := filepath.Join(dir, "SHA256SUMS.lock")
+	assert.NilError(t, os.WriteFile(lockFile, b.Bytes(), 0644))
+
+	fromLock, err := NewFromFiles(lockFile)
+	assert.NilError(t, err)
+	sp := fromLock["pool/main/h/hello/hello_2.10-2_amd64.deb"]
+	assert.Assert(t, sp != nil)
+	assert.Equal(t, "QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACU", sp.CID)
+	assert.Equal(t, int64(56132), sp.Size)
+	assert.Equal(t, "2.10-2+local1", sp.Dpkg.Version)
+	assert.Equal(t, epoch, *sp.Epoch)
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1234567890")
+	fromLock, err = NewFromFiles(lockFile)
+	assert.NilError(t, err)
+	assert.Equal(t, int64(1234567890), fromLock["pool/main/h/hello/hello_2.10-2_amd64.deb"].Epoch.Unix())
+}
+
+func TestNewFromBytes(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	sums := "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb\n"
+	modTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	fileSpecs, err := NewFromBytes([]byte(sums), modTime)
+	assert.NilError(t, err)
+	sp := fileSpecs["pool/main/h/hello/hello_2.10-2_amd64.deb"]
+	assert.Assert(t, sp != nil)
+	assert.Equal(t, "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", sp.SHA256)
+	assert.Equal(t, modTime, *sp.Epoch)
+
+	_, err = NewFromBytes([]byte("invalid\n"), modTime)
+	assert.ErrorContains(t, err, "failed to parse as SHA256SUMS")
+}
@@ -0,0 +1,74 @@
+package filespec
+
+import (
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/apkutil"
+	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
+	"github.com/reproducible-containers/repro-get/pkg/pacmanutil"
+	"github.com/reproducible-containers/repro-get/pkg/rpmutil"
+)
+
+// Package formats
+const (
+	FormatDpkg   = "dpkg"
+	FormatRPM    = "rpm"
+	FormatAPK    = "apk"
+	FormatPacman = "pacman"
+)
+
+// Package is the identity of the package contained in a file.
+type Package struct {
+	Format       string // "dpkg"
+	Name         string // "hello"
+	Version      string // "2.10-2" ("VERSION-RELEASE" for RPM)
+	Architecture string // "amd64" (empty for APK)
+}
+
+// Key returns the string that identifies the package regardless of the version, e.g., "dpkg:hello:amd64".
+func (p Package) Key() string {
+	return p.Format + ":" + p.Name + ":" + p.Architecture
+}
+
+// PackageKey returns the string that identifies the package regardless of the version (see Package.Key),
+// along with the identity of the package.
+// For a file that is not a known package, the key is "file:<NAME>", and the identity only has the name.
+func (sp FileSpec) PackageKey() (string, Package) {
+	if pkg := sp.Package(); pkg != nil {
+		return pkg.Key(), *pkg
+	}
+	return "file:" + sp.Name, Package{Name: sp.Name}
+}
+
+// Package returns the identity of the package, or nil if the file is not a known package.
+func (sp FileSpec) Package() *Package {
+	switch {
+	case sp.Dpkg != nil:
+		return &Package{Format: FormatDpkg, Name: sp.Dpkg.Package, Version: sp.Dpkg.Version, Architecture: sp.Dpkg.Architecture}
+	case sp.RPM != nil:
+		return &Package{Format: FormatRPM, Name: sp.RPM.Package, Version: sp.RPM.Version + "-" + sp.RPM.Release, Architecture: sp.RPM.Architecture}
+	case sp.APK != nil:
+		return &Package{Format: FormatAPK, Name: sp.APK.Package, Version: sp.APK.Version}
+	case sp.Pacman != nil:
+		return &Package{Format: FormatPacman, Name: sp.Pacman.Package, Version: sp.Pacman.Version, Architecture: sp.Pacman.Architecture}
+	default:
+		return nil
+	}
+}
+
+// CompareVersions compares the version strings with the ordering of the package format,
+// and returns -1, 0, or 1.
+func CompareVersions(format, a, b string) (int, error) {
+	switch format {
+	case FormatDpkg:
+		return dpkgutil.CompareVersions(a, b)
+	case FormatRPM:
+		return rpmutil.CompareVersions(a, b), nil
+	case FormatAPK:
+		return apkutil.CompareVersions(a, b)
+	case FormatPacman:
+		return pacmanutil.CompareVersions(a, b), nil
+	default:
+		return 0, fmt.Errorf("unknown package format %q", format)
+	}
+}
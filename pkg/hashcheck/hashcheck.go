@@ -0,0 +1,151 @@
+// Package hashcheck checks whether the files in a hash file are still available from the providers.
+package hashcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// Prober is implemented by *urlopener.URLOpener.
+type Prober interface {
+	// Probe returns the size of the file (-1 if unknown).
+	Probe(ctx context.Context, u *url.URL, sha256sum string) (int64, error)
+}
+
+type Opts struct {
+	Providers         []string
+	ArchivalProviders []string // Subset of Providers that are persistent but slow
+	Parallel          int
+	Prober            Prober
+}
+
+type Status string
+
+const (
+	// StatusAvailable means that the file is available from a non-archival provider.
+	StatusAvailable = Status("available")
+	// StatusArchivalOnly means that the file is only available from archival providers.
+	StatusArchivalOnly = Status("archival-only")
+	// StatusUnavailable means that the file is not available from any provider.
+	StatusUnavailable = Status("unavailable")
+)
+
+type ProviderResult struct {
+	Provider  string `json:"Provider"` // redacted
+	URL       string `json:"URL"`      // redacted
+	Archival  bool   `json:"Archival,omitempty"`
+	Available bool   `json:"Available"`
+	Error     string `json:"Error,omitempty"`
+}
+
+type Result struct {
+	Name      string           `json:"Name"`
+	SHA256    string           `json:"SHA256"`
+	Status    Status           `json:"Status"`
+	Providers []ProviderResult `json:"Providers"` // in the order of Opts.Providers; providers not applicable to the file are omitted
+}
+
+// Check probes the URLs of the files with every provider concurrently.
+// Providers that are not applicable to a file (e.g., "{{.CID}}" for a file without a CID) are skipped.
+// The results are sorted by the file name.
+func Check(ctx context.Context, fileSpecs map[string]*filespec.FileSpec, opts Opts) ([]Result, error) {
+	if len(opts.Providers) == 0 {
+		return nil, errors.New("provider needs to be specified")
+	}
+	if opts.Prober == nil {
+		return nil, errors.New("prober needs to be specified")
+	}
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	archival := make(map[string]bool, len(opts.ArchivalProviders))
+	for _, f := range opts.ArchivalProviders {
+		archival[f] = true
+	}
+
+	var fnames []string
+	for f := range fileSpecs {
+		fnames = append(fnames, f)
+	}
+	sort.Strings(fnames)
+
+	results := make([]Result, len(fnames))
+	urls := make([][]*url.URL, len(fnames)) // indexed like results[i].Providers
+	for i, fname := range fnames {
+		sp := fileSpecs[fname]
+		results[i] = Result{
+			Name:      sp.Name,
+			SHA256:    sp.SHA256,
+			Providers: []ProviderResult{},
+		}
+		for _, provider := range opts.Providers {
+			u, err := sp.URL(provider)
+			if err != nil {
+				logrus.WithError(err).Debugf("Skipping provider %q for %q", urlopener.RedactedString(provider), fname)
+				continue
+			}
+			results[i].Providers = append(results[i].Providers, ProviderResult{
+				Provider: urlopener.RedactedString(provider),
+				URL:      urlopener.Redacted(u),
+				Archival: archival[provider],
+			})
+			urls[i] = append(urls[i], u)
+		}
+	}
+
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(parallel)
+	for i, fname := range fnames {
+		sp := fileSpecs[fname]
+		for k, u := range urls[i] {
+			u, res := u, &results[i].Providers[k]
+			g.Go(func() error {
+				size, err := opts.Prober.Probe(gCtx, u, sp.SHA256)
+				if err == nil && sp.Size > 0 && size >= 0 && size != sp.Size {
+					err = fmt.Errorf("expected size %d, got %d", sp.Size, size)
+				}
+				if err != nil {
+					if ctxErr := gCtx.Err(); ctxErr != nil {
+						return ctxErr
+					}
+					logrus.WithError(err).Debugf("Not available: %q", res.URL)
+					res.Error = err.Error()
+					return nil
+				}
+				res.Available = true
+				return nil
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Status = status(results[i].Providers)
+	}
+	return results, nil
+}
+
+func status(providers []ProviderResult) Status {
+	res := StatusUnavailable
+	for _, f := range providers {
+		if !f.Available {
+			continue
+		}
+		if !f.Archival {
+			return StatusAvailable
+		}
+		res = StatusArchivalOnly
+	}
+	return res
+}
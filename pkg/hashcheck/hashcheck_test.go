@@ -0,0 +1,86 @@
+package hashcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"gotest.tools/v3/assert"
+)
+
+// newMirror returns an HTTP server that serves the specified files, with the number of the requests.
+func newMirror(t testing.TB, files ...string) (*httptest.Server, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		for _, f := range files {
+			if r.URL.Path == "/"+f {
+				w.Header().Set("Content-Length", "3")
+				if r.Method != http.MethodHead {
+					_, _ = w.Write([]byte("foo"))
+				}
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestCheck(t *testing.T) {
+	const (
+		hello = "pool/main/h/hello/hello_2.10-2_amd64.deb"
+		bash  = "pool/main/b/bash/bash_5.2-1_amd64.deb"
+		curl  = "pool/main/c/curl/curl_7.88.1-10_amd64.deb"
+	)
+	sums, err := sha256sums.Parse(strings.NewReader(strings.Repeat("0", 64) + "  " + hello + "\n" +
+		strings.Repeat("1", 64) + "  " + bash + "\n" +
+		strings.Repeat("2", 64) + "  " + curl + "\n"))
+	assert.NilError(t, err)
+	fileSpecs, err := filespec.NewFromSHA256SUMS(sums)
+	assert.NilError(t, err)
+
+	ephemeral, ephemeralRequests := newMirror(t, hello)
+	archival, archivalRequests := newMirror(t, hello, bash)
+	opts := Opts{
+		Providers:         []string{ephemeral.URL + "/{{.Name}}", archival.URL + "/{{.Name}}", "http://ipfs.example.com/ipfs/{{.CID}}"},
+		ArchivalProviders: []string{archival.URL + "/{{.Name}}"},
+		Parallel:          4,
+		Prober:            urlopener.New(),
+	}
+	results, err := Check(context.TODO(), fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, int32(3), atomic.LoadInt32(ephemeralRequests))
+	assert.Equal(t, int32(3), atomic.LoadInt32(archivalRequests))
+
+	statuses := make(map[string]Status)
+	for _, res := range results {
+		statuses[res.Name] = res.Status
+		assert.Equal(t, 2, len(res.Providers), "the CID provider must be skipped")
+	}
+	assert.DeepEqual(t, map[string]Status{
+		hello: StatusAvailable,
+		bash:  StatusArchivalOnly,
+		curl:  StatusUnavailable,
+	}, statuses)
+	assert.Equal(t, bash, results[0].Name, "must be sorted")
+	assert.Check(t, !results[0].Providers[0].Available)
+	assert.Check(t, strings.Contains(results[0].Providers[0].Error, "404"), results[0].Providers[0].Error)
+	assert.Check(t, results[0].Providers[1].Available)
+	assert.Check(t, results[0].Providers[1].Archival)
+
+	// Size mismatch
+	fileSpecs[hello].Size = 42
+	results, err = Check(context.TODO(), map[string]*filespec.FileSpec{hello: fileSpecs[hello]}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, StatusUnavailable, results[0].Status)
+	assert.Check(t, strings.Contains(results[0].Providers[0].Error, "expected size 42, got 3"), results[0].Providers[0].Error)
+}
@@ -0,0 +1,222 @@
+// Package hashdiff compares hash files at the package level.
+package hashdiff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+type ChangeType string
+
+const (
+	Added      = ChangeType("added")
+	Removed    = ChangeType("removed")
+	Upgraded   = ChangeType("upgraded")
+	Downgraded = ChangeType("downgraded")
+	// Changed is used when the digest changed without a version change (e.g., a rebuild),
+	// or when the versions cannot be compared.
+	Changed = ChangeType("changed")
+)
+
+type Change struct {
+	Type         ChangeType         `json:"Type"`
+	Format       string             `json:"Format,omitempty"` // empty for a file that is not a known package
+	Package      string             `json:"Package"`          // the file name for a file that is not a known package
+	Architecture string             `json:"Architecture,omitempty"`
+	OldVersion   string             `json:"OldVersion,omitempty"`
+	NewVersion   string             `json:"NewVersion,omitempty"`
+	Old          *filespec.FileSpec `json:"Old,omitempty"`
+	New          *filespec.FileSpec `json:"New,omitempty"`
+}
+
+type group struct {
+	pkg           filespec.Package
+	before, after []*filespec.FileSpec
+}
+
+func version(sp *filespec.FileSpec) string {
+	if pkg := sp.Package(); pkg != nil {
+		return pkg.Version
+	}
+	return ""
+}
+
+// Diff compares the file specs, and returns the changes sorted by the package name.
+// Files are matched by the package format, the package name, and the architecture.
+// Files that are not known packages are matched by the file name.
+func Diff(before, after map[string]*filespec.FileSpec) []Change {
+	groups := make(map[string]*group)
+	add := func(m map[string]*filespec.FileSpec, isAfter bool) {
+		for _, sp := range m {
+			k, pkg := sp.PackageKey()
+			g, ok := groups[k]
+			if !ok {
+				g = &group{pkg: pkg}
+				groups[k] = g
+			}
+			if isAfter {
+				g.after = append(g.after, sp)
+			} else {
+				g.before = append(g.before, sp)
+			}
+		}
+	}
+	add(before, false)
+	add(after, true)
+
+	var changes []Change
+	for _, g := range groups {
+		changes = append(changes, g.diff()...)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Architecture != b.Architecture {
+			return a.Architecture < b.Architecture
+		}
+		if a.Format != b.Format {
+			return a.Format < b.Format
+		}
+		if a.OldVersion != b.OldVersion {
+			return a.OldVersion < b.OldVersion
+		}
+		return a.NewVersion < b.NewVersion
+	})
+	return changes
+}
+
+func (g *group) change(typ ChangeType, before, after *filespec.FileSpec) Change {
+	c := Change{
+		Type:         typ,
+		Format:       g.pkg.Format,
+		Package:      g.pkg.Name,
+		Architecture: g.pkg.Architecture,
+		Old:          before,
+		New:          after,
+	}
+	if before != nil {
+		c.OldVersion = version(before)
+	}
+	if after != nil {
+		c.NewVersion = version(after)
+	}
+	return c
+}
+
+func (g *group) diff() []Change {
+	if len(g.before) == 1 && len(g.after) == 1 {
+		before, after := g.before[0], g.after[0]
+		if g.pkg.Format == "" {
+			if before.SHA256 == after.SHA256 {
+				return nil
+			}
+			return []Change{g.change(Changed, before, after)}
+		}
+		cmp, err := filespec.CompareVersions(g.pkg.Format, version(before), version(after))
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to compare the versions of %q", g.pkg.Name)
+			if version(before) == version(after) && before.SHA256 == after.SHA256 {
+				return nil
+			}
+			return []Change{g.change(Changed, before, after)}
+		}
+		switch {
+		case cmp < 0:
+			return []Change{g.change(Upgraded, before, after)}
+		case cmp > 0:
+			return []Change{g.change(Downgraded, before, after)}
+		case before.SHA256 != after.SHA256:
+			return []Change{g.change(Changed, before, after)}
+		default:
+			return nil
+		}
+	}
+	// Multiple versions of the same package (e.g., kernels) are matched by the version.
+	var changes []Change
+	afterByVersion := make(map[string]*filespec.FileSpec, len(g.after))
+	for _, sp := range g.after {
+		afterByVersion[version(sp)] = sp
+	}
+	beforeVersions := make(map[string]struct{}, len(g.before))
+	for _, before := range g.before {
+		v := version(before)
+		beforeVersions[v] = struct{}{}
+		after, ok := afterByVersion[v]
+		switch {
+		case !ok:
+			changes = append(changes, g.change(Removed, before, nil))
+		case before.SHA256 != after.SHA256:
+			changes = append(changes, g.change(Changed, before, after))
+		}
+	}
+	for _, after := range g.after {
+		if _, ok := beforeVersions[version(after)]; !ok {
+			changes = append(changes, g.change(Added, nil, after))
+		}
+	}
+	return changes
+}
+
+// Summary returns the number of the changes for each type, e.g., "1 added, 2 upgraded".
+func Summary(changes []Change) string {
+	counts := make(map[ChangeType]int)
+	for _, c := range changes {
+		counts[c.Type]++
+	}
+	var ss []string
+	for _, typ := range []ChangeType{Added, Removed, Upgraded, Downgraded, Changed} {
+		if n := counts[typ]; n > 0 {
+			ss = append(ss, fmt.Sprintf("%d %s", n, typ))
+		}
+	}
+	if len(ss) == 0 {
+		return "no changes"
+	}
+	return strings.Join(ss, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// WriteText writes the changes as a table.
+func WriteText(w io.Writer, changes []Change) error {
+	tw := tabwriter.NewWriter(w, 4, 8, 4, ' ', 0)
+	fmt.Fprintln(tw, "CHANGE\tPACKAGE\tARCH\tOLD\tNEW")
+	for _, c := range changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Type, c.Package, orNone(c.Architecture), orNone(c.OldVersion), orNone(c.NewVersion))
+	}
+	return tw.Flush()
+}
+
+// WriteMarkdown writes the changes as a Markdown table, e.g., for a pull request comment.
+func WriteMarkdown(w io.Writer, changes []Change) error {
+	if _, err := fmt.Fprintf(w, "%s\n\n", Summary(changes)); err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(w, "| Change | Package | Architecture | Old | New |\n|---|---|---|---|---|"); err != nil {
+		return err
+	}
+	escape := strings.NewReplacer("|", "\\|").Replace
+	for _, c := range changes {
+		if _, err := fmt.Fprintf(w, "| %s | `%s` | %s | %s | %s |\n", c.Type, escape(c.Package),
+			escape(orNone(c.Architecture)), escape(orNone(c.OldVersion)), escape(orNone(c.NewVersion))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package hashdiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"gotest.tools/v3/assert"
+)
+
+func parse(t testing.TB, s string) map[string]*filespec.FileSpec {
+	sums, err := sha256sums.Parse(strings.NewReader(s))
+	assert.NilError(t, err)
+	fileSpecs, err := filespec.NewFromSHA256SUMS(sums)
+	assert.NilError(t, err)
+	return fileSpecs
+}
+
+func TestDiff(t *testing.T) {
+	sha := func(c string) string { return strings.Repeat(c, 64) }
+	before := parse(t, sha("0")+"  pool/main/h/hello/hello_2.10-2_amd64.deb\n"+
+		sha("1")+"  pool/main/b/base-files/base-files_12.4_amd64.deb\n"+
+		sha("2")+"  pool/main/t/tzdata/tzdata_2023c-5_all.deb\n"+
+		sha("3")+"  pool/main/l/libc6/libc6_2.36-9_amd64.deb\n"+
+		sha("4")+"  pool/main/c/curl/curl_7.88.1-10_amd64.deb\n"+
+		sha("5")+"  README\n")
+	after := parse(t, sha("0")+"  pool/main/h/hello/hello_2.10-2_amd64.deb\n"+
+		sha("6")+"  pool/main/b/base-files/base-files_12.4+deb12u1_amd64.deb\n"+
+		sha("7")+"  pool/main/t/tzdata/tzdata_2023c-2_all.deb\n"+
+		sha("8")+"  pool/main/l/libc6/libc6_2.36-9_amd64.deb\n"+
+		sha("9")+"  pool/main/w/wget/wget_1.21.3-1+b2_amd64.deb\n"+
+		sha("a")+"  README\n")
+	changes := Diff(before, after)
+	var got []string
+	for _, c := range changes {
+		got = append(got, strings.Join([]string{string(c.Type), c.Package, c.Architecture, c.OldVersion, c.NewVersion}, " "))
+	}
+	expected := []string{
+		"changed README   ",
+		"upgraded base-files amd64 12.4 12.4+deb12u1",
+		"removed curl amd64 7.88.1-10 ",
+		"changed libc6 amd64 2.36-9 2.36-9",
+		"downgraded tzdata all 2023c-5 2023c-2",
+		"added wget amd64  1.21.3-1+b2",
+	}
+	assert.DeepEqual(t, expected, got)
+	assert.Equal(t, "1 added, 1 removed, 1 upgraded, 1 downgraded, 2 changed", Summary(changes))
+
+	var b bytes.Buffer
+	assert.NilError(t, WriteMarkdown(&b, changes))
+	assert.Check(t, strings.Contains(b.String(), "| upgraded | `base-files` | amd64 | 12.4 | 12.4+deb12u1 |\n"), b.String())
+
+	assert.Equal(t, 0, len(Diff(before, before)))
+}
+
+func TestDiffUncomparable(t *testing.T) {
+	sha := func(c string) string { return strings.Repeat(c, 64) }
+	before := parse(t, sha("0")+"  v3.18/main/x86_64/foo-1.0-r0.apk\n")
+	after := parse(t, sha("1")+"  v3.18/main/x86_64/foo-1.0-rX.apk\n")
+	changes := Diff(before, after)
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, Changed, changes[0].Type)
+	assert.Equal(t, "1.0-r0", changes[0].OldVersion)
+	assert.Equal(t, "1.0-rX", changes[0].NewVersion)
+}
@@ -0,0 +1,261 @@
+// Package hashmerge merges hash files with package-level conflict detection.
+package hashmerge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+// Prefer is the strategy for resolving conflicts.
+type Prefer string
+
+const (
+	// PreferNewest prefers the newest version.
+	// When the versions are same (or cannot be compared), the hash file with the newest epoch is preferred,
+	// and then the first hash file.
+	PreferNewest = Prefer("newest")
+	// PreferFirst prefers the first hash file.
+	PreferFirst = Prefer("first")
+	// PreferLast prefers the last hash file.
+	// Compatible with the versions of repro-get prior to the conflict detection, in which the later hash file wins.
+	PreferLast = Prefer("last")
+	// PreferFail fails on conflicts.
+	PreferFail = Prefer("fail")
+)
+
+// Prefers returns the known strategies.
+func Prefers() []Prefer {
+	return []Prefer{PreferNewest, PreferFirst, PreferLast, PreferFail}
+}
+
+// ParsePrefer parses the strategy.
+func ParsePrefer(s string) (Prefer, error) {
+	for _, f := range Prefers() {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown strategy %q (known strategies: %v)", s, Prefers())
+}
+
+// Input is a parsed hash file.
+type Input struct {
+	Name      string // the file name, used for messages
+	FileSpecs map[string]*filespec.FileSpec
+}
+
+// Candidate is the set of the files of a package in an input.
+// Usually contains a single file.
+type Candidate struct {
+	Input     string
+	FileSpecs []*filespec.FileSpec // sorted by the name
+}
+
+func (c Candidate) String() string {
+	ss := make([]string, len(c.FileSpecs))
+	for i, sp := range c.FileSpecs {
+		ss[i] = sp.SHA256[:12]
+		if pkg := sp.Package(); pkg != nil {
+			ss[i] = pkg.Version + " (" + ss[i] + ")"
+		}
+	}
+	return strings.Join(ss, ", ") + " in " + c.Input
+}
+
+// Conflict is a package that has different versions or digests across the inputs.
+type Conflict struct {
+	Format       string // empty for a file that is not a known package
+	Package      string // the file name for a file that is not a known package
+	Architecture string
+	Candidates   []Candidate // in the order of the inputs
+	Chosen       int         // index of Candidates, set by Merge unless PreferFail
+}
+
+func (c Conflict) String() string {
+	s := fmt.Sprintf("%q", c.Package)
+	if c.Format != "" {
+		s = c.Format + " package " + s
+		if c.Architecture != "" {
+			s += " (" + c.Architecture + ")"
+		}
+	}
+	ss := make([]string, len(c.Candidates))
+	for i, f := range c.Candidates {
+		ss[i] = f.String()
+	}
+	return s + ": " + strings.Join(ss, "; ")
+}
+
+// ConflictError is returned by Merge with PreferFail.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	ss := make([]string, len(e.Conflicts))
+	for i, f := range e.Conflicts {
+		ss[i] = f.String()
+	}
+	return fmt.Sprintf("%d conflict(s): %s", len(e.Conflicts), strings.Join(ss, "; "))
+}
+
+type group struct {
+	pkg        filespec.Package
+	candidates []Candidate
+}
+
+// Merge merges the inputs.
+//
+// A conflict is a package (same format, name, and architecture) or a non-package file (same name)
+// that appears in multiple inputs with different sets of digests.
+// Multiple versions of a package within a single input are not a conflict.
+//
+// Merge returns the merged file specs and the resolved conflicts.
+// With PreferFail, Merge returns *ConflictError on conflicts.
+// A file name with different digests across the inputs is an error, except with PreferLast.
+func Merge(inputs []Input, prefer Prefer) (map[string]*filespec.FileSpec, []Conflict, error) {
+	if _, err := ParsePrefer(string(prefer)); err != nil {
+		return nil, nil, err
+	}
+	groups := make(map[string]*group)
+	var keys []string
+	for _, in := range inputs {
+		byKey := make(map[string]*Candidate)
+		var inKeys []string
+		for _, sp := range in.FileSpecs {
+			k, pkg := sp.PackageKey()
+			if _, ok := groups[k]; !ok {
+				groups[k] = &group{pkg: pkg}
+				keys = append(keys, k)
+			}
+			c, ok := byKey[k]
+			if !ok {
+				c = &Candidate{Input: in.Name}
+				byKey[k] = c
+				inKeys = append(inKeys, k)
+			}
+			c.FileSpecs = append(c.FileSpecs, sp)
+		}
+		for _, k := range inKeys {
+			c := byKey[k]
+			sort.Slice(c.FileSpecs, func(i, j int) bool { return c.FileSpecs[i].Name < c.FileSpecs[j].Name })
+			groups[k].candidates = append(groups[k].candidates, *c)
+		}
+	}
+	sort.Strings(keys)
+
+	res := make(map[string]*filespec.FileSpec)
+	origins := make(map[string]string) // key: file name, value: input name
+	var conflicts []Conflict
+	for _, k := range keys {
+		g := groups[k]
+		candidates := g.candidates
+		if g.conflicts() {
+			conflict := Conflict{
+				Format:       g.pkg.Format,
+				Package:      g.pkg.Name,
+				Architecture: g.pkg.Architecture,
+				Candidates:   g.candidates,
+			}
+			switch prefer {
+			case PreferNewest:
+				conflict.Chosen = g.newest()
+			case PreferLast:
+				conflict.Chosen = len(g.candidates) - 1
+			}
+			conflicts = append(conflicts, conflict)
+			candidates = g.candidates[conflict.Chosen : conflict.Chosen+1]
+		}
+		// Candidates without conflicts are still merged, as the names may differ (e.g., pool paths)
+		for _, c := range candidates {
+			for _, sp := range c.FileSpecs {
+				if existing, ok := res[sp.Name]; ok && existing.SHA256 != sp.SHA256 {
+					if prefer == PreferLast {
+						logrus.Warnf("File %q has conflicting digests %s (in %s) and %s (in %s), choosing the latter",
+							sp.Name, existing.SHA256, origins[sp.Name], sp.SHA256, c.Input)
+						res[sp.Name] = sp
+						origins[sp.Name] = c.Input
+						continue
+					}
+					return nil, conflicts, fmt.Errorf("file %q has conflicting digests %s (in %s) and %s (in %s)",
+						sp.Name, existing.SHA256, origins[sp.Name], sp.SHA256, c.Input)
+				}
+				res[sp.Name] = sp
+				origins[sp.Name] = c.Input
+			}
+		}
+	}
+	if prefer == PreferFail && len(conflicts) > 0 {
+		return nil, conflicts, &ConflictError{Conflicts: conflicts}
+	}
+	return res, conflicts, nil
+}
+
+func digests(c Candidate) string {
+	ss := make([]string, len(c.FileSpecs))
+	for i, sp := range c.FileSpecs {
+		ss[i] = sp.SHA256
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, ",")
+}
+
+func (g *group) conflicts() bool {
+	for _, c := range g.candidates[1:] {
+		if digests(c) != digests(g.candidates[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// newest returns the index of the candidate with the newest version.
+func (g *group) newest() int {
+	chosen := 0
+	for i := 1; i < len(g.candidates); i++ {
+		if g.compare(g.candidates[i], g.candidates[chosen]) > 0 {
+			chosen = i
+		}
+	}
+	return chosen
+}
+
+func (g *group) compare(a, b Candidate) int {
+	if g.pkg.Format != "" {
+		cmp, err := filespec.CompareVersions(g.pkg.Format, maxVersion(g.pkg.Format, a), maxVersion(g.pkg.Format, b))
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to compare the versions of %q", g.pkg.Name)
+		} else if cmp != 0 {
+			return cmp
+		}
+	}
+	aEpoch, bEpoch := a.FileSpecs[0].Epoch, b.FileSpecs[0].Epoch
+	if aEpoch != nil && bEpoch != nil {
+		switch {
+		case aEpoch.After(*bEpoch):
+			return 1
+		case aEpoch.Before(*bEpoch):
+			return -1
+		}
+	}
+	return 0
+}
+
+func maxVersion(format string, c Candidate) string {
+	var res string
+	for _, sp := range c.FileSpecs {
+		v := sp.Package().Version
+		if res == "" {
+			res = v
+			continue
+		}
+		if cmp, err := filespec.CompareVersions(format, v, res); err == nil && cmp > 0 {
+			res = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,85 @@
+package hashmerge
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"gotest.tools/v3/assert"
+)
+
+func input(t testing.TB, name string, epoch time.Time, s string) Input {
+	sums, err := sha256sums.Parse(strings.NewReader(s))
+	assert.NilError(t, err)
+	fileSpecs, err := filespec.NewFromSHA256SUMS(sums, filespec.WithHashMapEpoch(&epoch))
+	assert.NilError(t, err)
+	return Input{Name: name, FileSpecs: fileSpecs}
+}
+
+func names(m map[string]*filespec.FileSpec) []string {
+	var res []string
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestMerge(t *testing.T) {
+	sha := func(c string) string { return strings.Repeat(c, 64) }
+	t0 := time.Unix(1672531200, 0).UTC()
+	inputs := []Input{
+		input(t, "base", t0.Add(time.Hour), sha("0")+"  pool/main/h/hello/hello_2.10-3_amd64.deb\n"+
+			sha("1")+"  pool/main/b/base-files/base-files_12.4_amd64.deb\n"+
+			sha("2")+"  pool/main/t/tzdata/tzdata_2023c-5_all.deb\n"),
+		input(t, "extra", t0, sha("3")+"  pool/main/h/hello/hello_2.10-2_amd64.deb\n"+
+			sha("1")+"  pool/main/b/base-files/base-files_12.4_amd64.deb\n"+
+			sha("4")+"  pool/main/c/curl/curl_7.88.1-10_amd64.deb\n"+
+			sha("5")+"  pool/main/t/tzdata/tzdata_2023c-5_all.deb\n"),
+	}
+
+	_, conflicts, err := Merge(inputs, PreferFail)
+	var conflictErr *ConflictError
+	assert.Check(t, errors.As(err, &conflictErr), err)
+	assert.Equal(t, 2, len(conflicts))
+	assert.ErrorContains(t, err, `dpkg package "hello" (amd64): 2.10-3 (000000000000) in base; 2.10-2 (333333333333) in extra`)
+
+	merged, conflicts, err := Merge(inputs, PreferFirst)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(conflicts))
+	assert.DeepEqual(t, []string{
+		"pool/main/b/base-files/base-files_12.4_amd64.deb",
+		"pool/main/c/curl/curl_7.88.1-10_amd64.deb",
+		"pool/main/h/hello/hello_2.10-3_amd64.deb",
+		"pool/main/t/tzdata/tzdata_2023c-5_all.deb",
+	}, names(merged))
+	assert.Equal(t, sha("2"), merged["pool/main/t/tzdata/tzdata_2023c-5_all.deb"].SHA256)
+
+	merged, conflicts, err = Merge(inputs, PreferLast)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(conflicts))
+	assert.Equal(t, 1, conflicts[0].Chosen)
+	assert.DeepEqual(t, []string{
+		"pool/main/b/base-files/base-files_12.4_amd64.deb",
+		"pool/main/c/curl/curl_7.88.1-10_amd64.deb",
+		"pool/main/h/hello/hello_2.10-2_amd64.deb",
+		"pool/main/t/tzdata/tzdata_2023c-5_all.deb",
+	}, names(merged))
+	assert.Equal(t, sha("5"), merged["pool/main/t/tzdata/tzdata_2023c-5_all.deb"].SHA256)
+
+	// Reverse the order, so that "first" and "newest" differ
+	inputs[0], inputs[1] = inputs[1], inputs[0]
+	merged, _, err = Merge(inputs, PreferNewest)
+	assert.NilError(t, err)
+	assert.Check(t, merged["pool/main/h/hello/hello_2.10-3_amd64.deb"] != nil)
+	assert.Check(t, merged["pool/main/h/hello/hello_2.10-2_amd64.deb"] == nil)
+	// Same version, so the newer epoch wins
+	assert.Equal(t, sha("2"), merged["pool/main/t/tzdata/tzdata_2023c-5_all.deb"].SHA256)
+
+	_, _, err = Merge(inputs, Prefer("foo"))
+	assert.ErrorContains(t, err, "unknown strategy")
+}
@@ -0,0 +1,151 @@
+// Package httpauth provides the credentials for HTTP and HTTPS providers.
+//
+// The credentials are looked up by the host, from the following files:
+//
+//   - the repro-get credentials file (default: ~/.config/repro-get/credentials.json)
+//
+//   - the netrc file (default: $NETRC, or ~/.netrc)
+//
+// The repro-get credentials file takes precedence over the netrc file.
+//
+// An example of the repro-get credentials file:
+//
+//	{
+//	  "Hosts": {
+//	    "artifactory.example.com": {"Username": "foo", "Password": "bar"},
+//	    "mirror.example.com:8443": {"Token": "<BEARER-TOKEN>"}
+//	  }
+//	}
+package httpauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Credential struct {
+	Username string `json:"Username,omitempty"`
+	Password string `json:"Password,omitempty"`
+	Token    string `json:"Token,omitempty"` // Bearer token, takes precedence over Username and Password
+}
+
+// Apply sets the Authorization header.
+func (c *Credential) Apply(req *http.Request) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+		return
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+}
+
+type CredentialsFile struct {
+	Hosts map[string]Credential `json:"Hosts"` // key: "host" or "host:port"
+}
+
+// Store is a set of credentials, keyed by "host" or "host:port".
+type Store struct {
+	byHost map[string]Credential
+}
+
+func NewStore() *Store {
+	return &Store{
+		byHost: make(map[string]Credential),
+	}
+}
+
+// Add adds the credential. Existing credential for the host is overwritten.
+func (s *Store) Add(host string, c Credential) {
+	s.byHost[host] = c
+}
+
+// Lookup looks up the credential for "host:port" first, and then for "host".
+func (s *Store) Lookup(hostPort string) (*Credential, bool) {
+	if s == nil {
+		return nil, false
+	}
+	if c, ok := s.byHost[hostPort]; ok {
+		return &c, true
+	}
+	if host, _, err := net.SplitHostPort(hostPort); err == nil {
+		if c, ok := s.byHost[host]; ok {
+			return &c, true
+		}
+	}
+	return nil, false
+}
+
+// DefaultNetrcFile returns $NETRC, or ~/.netrc.
+func DefaultNetrcFile() string {
+	if v := os.Getenv("NETRC"); v != "" {
+		return v
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// DefaultCredentialsFile returns ~/.config/repro-get/credentials.json.
+func DefaultCredentialsFile() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "repro-get", "credentials.json")
+}
+
+// Load loads the credentials from the netrc file and the repro-get credentials file.
+// Non-existent files are ignored.
+func Load(netrcFile, credentialsFile string) (*Store, error) {
+	s := NewStore()
+	if netrcFile != "" {
+		b, err := readSecretFile(netrcFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			m, err := ParseNetrc(b)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %q: %w", netrcFile, err)
+			}
+			for host, c := range m {
+				s.Add(host, c)
+			}
+		}
+	}
+	if credentialsFile != "" {
+		b, err := readSecretFile(credentialsFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			var f CredentialsFile
+			if err = json.Unmarshal(b, &f); err != nil {
+				return nil, fmt.Errorf("failed to parse %q: %w", credentialsFile, err)
+			}
+			for host, c := range f.Hosts {
+				s.Add(host, c)
+			}
+		}
+	}
+	return s, nil
+}
+
+func readSecretFile(f string) ([]byte, error) {
+	st, err := os.Stat(f)
+	if err != nil {
+		return nil, err
+	}
+	if st.Mode().Perm()&0o077 != 0 {
+		logrus.Warnf("Credential file %q is accessible by other users (mode %v)", f, st.Mode().Perm())
+	}
+	return os.ReadFile(f)
+}
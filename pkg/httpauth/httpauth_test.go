@@ -0,0 +1,67 @@
+package httpauth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseNetrc(t *testing.T) {
+	const netrc = `
+# comment
+machine example.com login foo password bar
+machine example.org
+  login baz
+  password qux
+macdef init
+  cd /pub
+
+default login anonymous password anonymous@example.com
+`
+	m, err := ParseNetrc([]byte(netrc))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]Credential{
+		"example.com": {Username: "foo", Password: "bar"},
+		"example.org": {Username: "baz", Password: "qux"},
+	}, m)
+
+	_, err = ParseNetrc([]byte("machine example.com login"))
+	assert.ErrorContains(t, err, "missing value")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	netrcFile := filepath.Join(dir, "netrc")
+	assert.NilError(t, os.WriteFile(netrcFile, []byte("machine example.com login foo password bar\nmachine example.org login baz password qux\n"), 0600))
+	credsFile := filepath.Join(dir, "credentials.json")
+	assert.NilError(t, os.WriteFile(credsFile, []byte(`{"Hosts": {"example.org": {"Token": "tok"}, "example.net:8443": {"Username": "u", "Password": "p"}}}`), 0600))
+
+	s, err := Load(netrcFile, credsFile)
+	assert.NilError(t, err)
+
+	c, ok := s.Lookup("example.com")
+	assert.Check(t, ok)
+	assert.DeepEqual(t, &Credential{Username: "foo", Password: "bar"}, c)
+
+	c, ok = s.Lookup("example.com:443")
+	assert.Check(t, ok)
+	assert.DeepEqual(t, &Credential{Username: "foo", Password: "bar"}, c)
+
+	// credentials.json takes precedence over netrc
+	c, ok = s.Lookup("example.org")
+	assert.Check(t, ok)
+	assert.DeepEqual(t, &Credential{Token: "tok"}, c)
+
+	_, ok = s.Lookup("example.net")
+	assert.Check(t, !ok)
+	_, ok = s.Lookup("example.net:8443")
+	assert.Check(t, ok)
+
+	// Non-existent files are ignored
+	s, err = Load(filepath.Join(dir, "non-existent"), filepath.Join(dir, "non-existent.json"))
+	assert.NilError(t, err)
+	_, ok = s.Lookup("example.com")
+	assert.Check(t, !ok)
+}
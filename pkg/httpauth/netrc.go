@@ -0,0 +1,84 @@
+package httpauth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ParseNetrc parses a netrc file and returns the credentials keyed by the machine name.
+// The "default" entry is ignored, so as to avoid leaking the credentials to arbitrary hosts.
+func ParseNetrc(b []byte) (map[string]Credential, error) {
+	res := make(map[string]Credential)
+	var (
+		machine   string
+		inDefault bool
+		cred      Credential
+	)
+	flush := func() {
+		if machine != "" {
+			res[machine] = cred
+		}
+		machine, inDefault, cred = "", false, Credential{}
+	}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	inMacdef := false
+	for sc.Scan() {
+		line := sc.Text()
+		if inMacdef {
+			// A macro definition ends with an empty line
+			if strings.TrimSpace(line) == "" {
+				inMacdef = false
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			next := func() (string, error) {
+				if i+1 >= len(fields) {
+					return "", fmt.Errorf("missing value for %q", fields[i])
+				}
+				i++
+				return fields[i], nil
+			}
+			var err error
+			switch fields[i] {
+			case "machine":
+				flush()
+				machine, err = next()
+			case "default":
+				flush()
+				inDefault = true
+			case "login":
+				var v string
+				v, err = next()
+				if !inDefault {
+					cred.Username = v
+				}
+			case "password":
+				var v string
+				v, err = next()
+				if !inDefault {
+					cred.Password = v
+				}
+			case "account":
+				_, err = next()
+			case "macdef":
+				_, err = next()
+				inMacdef = true
+				i = len(fields)
+			default:
+				err = fmt.Errorf("unexpected token %q", fields[i])
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	flush()
+	return res, sc.Err()
+}
@@ -0,0 +1,155 @@
+// Package lockfile implements the lockfile, a structured alternative to the SHA256SUMS hash file.
+//
+// Unlike SHA256SUMS, the lockfile can hold the sizes, the package identities, the provider hints (CID and OCIRef),
+// the epoch, and the distro.
+//
+// Example:
+//
+//	{
+//	  "Version": 1,
+//	  "Distro": "debian",
+//	  "Epoch": "2023-01-01T00:00:00Z",
+//	  "Files": [
+//	    {
+//	      "Name": "pool/main/h/hello/hello_2.10-2_amd64.deb",
+//	      "SHA256": "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+//	      "Size": 56132,
+//	      "Dpkg": {
+//	        "Package": "hello",
+//	        "Version": "2.10-2",
+//	        "Architecture": "amd64"
+//	      }
+//	    }
+//	  ]
+//	}
+package lockfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+	"unicode"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/apkutil"
+	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
+	"github.com/reproducible-containers/repro-get/pkg/pacmanutil"
+	"github.com/reproducible-containers/repro-get/pkg/rpmutil"
+)
+
+// Version is the version of the lockfile format written by this version of repro-get.
+const Version = 1
+
+type Lockfile struct {
+	Version int        `json:"Version"`          // Version
+	Distro  string     `json:"Distro,omitempty"` // "debian", "ubuntu", ...
+	Epoch   *time.Time `json:"Epoch,omitempty"`  // Timestamp of the generation, used instead of the file's mtime
+	Files   []File     `json:"Files"`            // Sorted by Name
+}
+
+type File struct {
+	Name   string             `json:"Name"`             // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	SHA256 string             `json:"SHA256"`           // "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
+	Size   int64              `json:"Size,omitempty"`   // Zero if unknown
+	CID    string             `json:"CID,omitempty"`    // IPFS CID
+	OCIRef string             `json:"OCIRef,omitempty"` // OCI repository that contains the blob, e.g., "ghcr.io/USERNAME/dpkgs"
+	Dpkg   *dpkgutil.Dpkg     `json:"Dpkg,omitempty"`
+	RPM    *rpmutil.RPM       `json:"RPM,omitempty"`
+	APK    *apkutil.APK       `json:"APK,omitempty"`
+	Pacman *pacmanutil.Pacman `json:"Pacman,omitempty"`
+}
+
+// IsLockfile returns true if b looks like a lockfile rather than SHA256SUMS.
+func IsLockfile(b []byte) bool {
+	b = bytes.TrimLeftFunc(b, unicode.IsSpace)
+	return len(b) > 0 && b[0] == '{'
+}
+
+// Parse parses the lockfile.
+func Parse(r io.Reader) (*Lockfile, error) {
+	var l Lockfile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&l); err != nil {
+		return nil, err
+	}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Validate validates the lockfile.
+// The file names are validated by package filespec.
+func (l *Lockfile) Validate() error {
+	if l.Version < 1 || l.Version > Version {
+		return fmt.Errorf("unsupported lockfile version %d (supported: %d)", l.Version, Version)
+	}
+	seen := make(map[string]struct{}, len(l.Files))
+	for i, f := range l.Files {
+		if f.Name == "" {
+			return fmt.Errorf("file %d: empty name", i)
+		}
+		if _, ok := seen[f.Name]; ok {
+			return fmt.Errorf("file %q: duplicated", f.Name)
+		}
+		seen[f.Name] = struct{}{}
+		if err := digest.SHA256.Validate(f.SHA256); err != nil {
+			return fmt.Errorf("file %q: %w", f.Name, err)
+		}
+		if f.Size < 0 {
+			return fmt.Errorf("file %q: invalid size %d", f.Name, f.Size)
+		}
+	}
+	return nil
+}
+
+// Sort sorts the files by name.
+func (l *Lockfile) Sort() {
+	sort.Slice(l.Files, func(i, j int) bool {
+		return l.Files[i].Name < l.Files[j].Name
+	})
+}
+
+// Write writes the lockfile as an indented JSON, with the files sorted by name.
+func (l *Lockfile) Write(w io.Writer) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	l.Sort()
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteSHA256SUMS writes the lockfile in the SHA256SUMS format.
+// The CIDs and the OCI refs are written as the pseudo file names ("/ipfs/<CID>", "/oci/<REF>").
+// The other fields are lost.
+func (l *Lockfile) WriteSHA256SUMS(w io.Writer) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	l.Sort()
+	for _, f := range l.Files {
+		if _, err := fmt.Fprintf(w, "%s  %s\n", f.SHA256, f.Name); err != nil {
+			return err
+		}
+		if f.CID != "" {
+			if _, err := fmt.Fprintf(w, "%s  /ipfs/%s\n", f.SHA256, f.CID); err != nil {
+				return err
+			}
+		}
+		if f.OCIRef != "" {
+			if _, err := fmt.Fprintf(w, "%s  /oci/%s\n", f.SHA256, f.OCIRef); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
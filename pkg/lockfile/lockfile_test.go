@@ -0,0 +1,83 @@
+package lockfile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const testLockfile = `{
+  "Version": 1,
+  "Distro": "debian",
+  "Epoch": "2023-01-01T00:00:00Z",
+  "Files": [
+    {
+      "Name": "pool/main/h/hello/hello_2.10-2_amd64.deb",
+      "SHA256": "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+      "Size": 56132,
+      "CID": "QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACU",
+      "Dpkg": {
+        "Package": "hello",
+        "Version": "2.10-2",
+        "Architecture": "amd64"
+      }
+    },
+    {
+      "Name": "pool/main/b/base-files/base-files_12.4+deb12u1_amd64.deb",
+      "SHA256": "0ae2fbd8ec1e8a8ef7cc14a7ae7d3e1d1ea7f6e1a0e4d9c0d8b2f0f4b5e0f7a1",
+      "OCIRef": "ghcr.io/USERNAME/dpkgs"
+    }
+  ]
+}
+`
+
+func TestLockfile(t *testing.T) {
+	assert.Check(t, IsLockfile([]byte("\n  "+testLockfile)))
+	assert.Check(t, !IsLockfile([]byte("35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  hello_2.10-2_amd64.deb\n")))
+	assert.Check(t, !IsLockfile(nil))
+
+	l, err := Parse(strings.NewReader(testLockfile))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, l.Version)
+	assert.Equal(t, "debian", l.Distro)
+	assert.Equal(t, int64(1672531200), l.Epoch.Unix())
+	assert.Equal(t, 2, len(l.Files))
+	assert.Equal(t, "hello", l.Files[0].Dpkg.Package)
+
+	var b bytes.Buffer
+	assert.NilError(t, l.Write(&b))
+	assert.Equal(t, "pool/main/b/base-files/base-files_12.4+deb12u1_amd64.deb", l.Files[0].Name, "must be sorted")
+	l2, err := Parse(&b)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, l, l2)
+
+	b.Reset()
+	assert.NilError(t, l.WriteSHA256SUMS(&b))
+	expected := `0ae2fbd8ec1e8a8ef7cc14a7ae7d3e1d1ea7f6e1a0e4d9c0d8b2f0f4b5e0f7a1  pool/main/b/base-files/base-files_12.4+deb12u1_amd64.deb
+0ae2fbd8ec1e8a8ef7cc14a7ae7d3e1d1ea7f6e1a0e4d9c0d8b2f0f4b5e0f7a1  /oci/ghcr.io/USERNAME/dpkgs
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  /ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACU
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestParseInvalid(t *testing.T) {
+	sha0, sha1 := strings.Repeat("0", 64), strings.Repeat("1", 64)
+	testCases := []struct {
+		s        string
+		expected string
+	}{
+		{`{"Version":2,"Files":[]}`, "unsupported lockfile version"},
+		{`{"Files":[]}`, "unsupported lockfile version"},
+		{`{"Version":1,"Files":[{"Name":"foo","SHA256":"bar"}]}`, "file \"foo\""},
+		{`{"Version":1,"Files":[],"Foo":1}`, "unknown field"},
+		{`{"Version":1,"Files":[{"SHA256":"` + sha0 + `"}]}`, "empty name"},
+		{`{"Version":1,"Files":[{"Name":"foo","SHA256":"` + sha0 + `"},{"Name":"foo","SHA256":"` + sha1 + `"}]}`, "duplicated"},
+	}
+	for _, tc := range testCases {
+		_, err := Parse(strings.NewReader(tc.s))
+		assert.ErrorContains(t, err, tc.expected, tc.s)
+	}
+}
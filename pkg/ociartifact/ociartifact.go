@@ -0,0 +1,111 @@
+// Package ociartifact converts a set of files to an OCI artifact manifest, and vice versa.
+//
+// The artifact manifest is an OCI image manifest with the empty config,
+// and each layer is a file, with the basename in the "org.opencontainers.image.title" annotation.
+// This format is compatible with ORAS.
+package ociartifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// ArtifactType is the artifact type of the manifest.
+	ArtifactType = "application/vnd.reproducible-containers.repro-get.v1"
+
+	// MediaTypeFile is the media type of the layers.
+	// Other media types are accepted on reading.
+	MediaTypeFile = "application/octet-stream"
+)
+
+// File is a file in the artifact.
+type File struct {
+	SHA256   string
+	Size     int64
+	Basename string // Optional
+}
+
+func (f *File) Descriptor() ocispec.Descriptor {
+	desc := ocispec.Descriptor{
+		MediaType: MediaTypeFile,
+		Digest:    digest.NewDigestFromEncoded(digest.SHA256, f.SHA256),
+		Size:      f.Size,
+	}
+	if f.Basename != "" {
+		desc.Annotations = map[string]string{
+			ocispec.AnnotationTitle: f.Basename,
+		}
+	}
+	return desc
+}
+
+// NewManifest creates an artifact manifest.
+// The layers are sorted by the basenames and the digests, for reproducibility.
+func NewManifest(files []File) *ocispec.Manifest {
+	files = append([]File(nil), files...)
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Basename != files[j].Basename {
+			return files[i].Basename < files[j].Basename
+		}
+		return files[i].SHA256 < files[j].SHA256
+	})
+	layers := make([]ocispec.Descriptor, len(files))
+	for i := range files {
+		layers[i] = files[i].Descriptor()
+	}
+	config := ocispec.DescriptorEmptyJSON
+	config.Data = nil // embedding the data is optional, and not supported by some registries
+	return &ocispec.Manifest{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: ArtifactType,
+		Config:       config,
+		Layers:       layers,
+	}
+}
+
+// EmptyConfig is the content of ocispec.DescriptorEmptyJSON.
+var EmptyConfig = []byte("{}")
+
+// MarshalManifest marshals the manifest, and returns its descriptor.
+func MarshalManifest(m *ocispec.Manifest) ([]byte, ocispec.Descriptor, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, err
+	}
+	desc := ocispec.Descriptor{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: m.ArtifactType,
+		Digest:       digest.SHA256.FromBytes(b),
+		Size:         int64(len(b)),
+	}
+	return b, desc, nil
+}
+
+// Files returns the files in the manifest.
+// The manifest does not need to be created by NewManifest.
+func Files(m *ocispec.Manifest) ([]File, error) {
+	files := make([]File, len(m.Layers))
+	for i, desc := range m.Layers {
+		if desc.Digest.Algorithm() != digest.SHA256 {
+			return nil, fmt.Errorf("expected algorithm %q, got %q (%q)", digest.SHA256, desc.Digest.Algorithm(), desc.Digest)
+		}
+		if err := desc.Digest.Validate(); err != nil {
+			return nil, err
+		}
+		files[i] = File{
+			SHA256:   desc.Digest.Encoded(),
+			Size:     desc.Size,
+			Basename: desc.Annotations[ocispec.AnnotationTitle],
+		}
+	}
+	return files, nil
+}
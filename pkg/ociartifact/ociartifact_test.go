@@ -0,0 +1,42 @@
+package ociartifact
+
+import (
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/v3/assert"
+)
+
+func TestManifest(t *testing.T) {
+	const (
+		sha256A = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		sha256B = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	files := []File{
+		{SHA256: sha256B, Size: 2, Basename: "hello_2.10-2_amd64.deb"},
+		{SHA256: sha256A, Size: 1, Basename: "hello_2.10-2_amd64.deb"},
+		{SHA256: sha256A, Size: 1},
+	}
+	m := NewManifest(files)
+	assert.Equal(t, ArtifactType, m.ArtifactType)
+	assert.Equal(t, ocispec.MediaTypeEmptyJSON, m.Config.MediaType)
+	assert.Equal(t, 3, len(m.Layers))
+	assert.Equal(t, "", m.Layers[0].Annotations[ocispec.AnnotationTitle])
+	assert.Equal(t, sha256A, m.Layers[1].Digest.Encoded())
+	assert.Equal(t, sha256B, m.Layers[2].Digest.Encoded())
+
+	b, desc, err := MarshalManifest(m)
+	assert.NilError(t, err)
+	assert.Equal(t, ocispec.MediaTypeImageManifest, desc.MediaType)
+	assert.Equal(t, ArtifactType, desc.ArtifactType)
+
+	files2 := append([]File(nil), files[2], files[1], files[0])
+	b2, desc2, err := MarshalManifest(NewManifest(files2))
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), string(b2))
+	assert.Equal(t, desc.Digest, desc2.Digest)
+
+	parsed, err := Files(m)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []File{files[2], files[1], files[0]}, parsed)
+}
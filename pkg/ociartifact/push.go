@@ -0,0 +1,90 @@
+package ociartifact
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// Opener opens a file to be pushed.
+type Opener func(f File) (io.ReadCloser, error)
+
+// Push pushes the files and the artifact manifest that lists them.
+// ref is a reference string such as "ghcr.io/USERNAME/dpkgs:latest".
+// Blobs that already exist in the registry are skipped.
+func Push(ctx context.Context, resolver remotes.Resolver, ref string, files []File, open Opener) (*ocispec.Descriptor, error) {
+	// Suppress "reference for unknown type" warnings
+	ctx = remotes.WithMediaTypeKeyPrefix(ctx, MediaTypeFile, "file")
+	ctx = remotes.WithMediaTypeKeyPrefix(ctx, ocispec.MediaTypeEmptyJSON, "config")
+	pusher, err := resolver.Pusher(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pusher for %q: %w", ref, err)
+	}
+	for _, f := range files {
+		if err := pushFile(ctx, pusher, f, open); err != nil {
+			return nil, err
+		}
+	}
+	emptyConfig := ocispec.DescriptorEmptyJSON
+	if err := pushBytes(ctx, pusher, emptyConfig, EmptyConfig); err != nil {
+		return nil, fmt.Errorf("failed to push the config: %w", err)
+	}
+	manifest := NewManifest(files)
+	manifestJSON, manifestDesc, err := MarshalManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := pushBytes(ctx, pusher, manifestDesc, manifestJSON); err != nil {
+		return nil, fmt.Errorf("failed to push the manifest: %w", err)
+	}
+	return &manifestDesc, nil
+}
+
+func pushFile(ctx context.Context, pusher remotes.Pusher, f File, open Opener) error {
+	desc := f.Descriptor()
+	w, err := pusher.Push(ctx, desc)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			logrus.Debugf("Already exists: %s (%q)", desc.Digest, f.Basename)
+			return nil
+		}
+		return fmt.Errorf("failed to push %s (%q): %w", desc.Digest, f.Basename, err)
+	}
+	defer w.Close()
+	r, err := open(f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if _, err = io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to push %s (%q): %w", desc.Digest, f.Basename, err)
+	}
+	if err = w.Commit(ctx, desc.Size, desc.Digest); err != nil && !errdefs.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to commit %s (%q): %w", desc.Digest, f.Basename, err)
+	}
+	return nil
+}
+
+func pushBytes(ctx context.Context, pusher remotes.Pusher, desc ocispec.Descriptor, b []byte) error {
+	w, err := pusher.Push(ctx, desc)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	defer w.Close()
+	if _, err = io.Copy(w, bytes.NewReader(b)); err != nil {
+		return err
+	}
+	if err = w.Commit(ctx, desc.Size, desc.Digest); err != nil && !errdefs.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package ociartifact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/v3/assert"
+)
+
+// testRegistry is a minimal in-memory OCI registry that only supports a single repository.
+type testRegistry struct {
+	t         testing.TB
+	repo      string
+	mu        sync.Mutex
+	blobs     map[digest.Digest][]byte
+	manifests map[string][]byte // key: tag or digest
+	uploads   int
+}
+
+func newTestRegistry(t testing.TB, repo string) *testRegistry {
+	return &testRegistry{
+		t:         t,
+		repo:      repo,
+		blobs:     make(map[digest.Digest][]byte),
+		manifests: make(map[string][]byte),
+	}
+}
+
+func (reg *testRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	prefix := "/v2/" + reg.repo + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, prefix)
+	reply := func(b []byte, contentType string) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(b)))
+		w.Header().Set("Docker-Content-Digest", digest.SHA256.FromBytes(b).String())
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(b)
+		}
+	}
+	switch {
+	case r.Method == http.MethodPost && p == "blobs/uploads/":
+		w.Header().Set("Location", prefix+"blobs/uploads/dummy")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && p == "blobs/uploads/dummy":
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			reg.t.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		dgst := digest.Digest(r.URL.Query().Get("digest"))
+		if dgst != digest.SHA256.FromBytes(b) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reg.blobs[dgst] = b
+		reg.uploads++
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(p, "blobs/"):
+		b, ok := reg.blobs[digest.Digest(strings.TrimPrefix(p, "blobs/"))]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply(b, "application/octet-stream")
+	case r.Method == http.MethodPut && strings.HasPrefix(p, "manifests/"):
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			reg.t.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		dgst := digest.SHA256.FromBytes(b)
+		reg.manifests[strings.TrimPrefix(p, "manifests/")] = b
+		reg.manifests[dgst.String()] = b
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(p, "manifests/"):
+		b, ok := reg.manifests[strings.TrimPrefix(p, "manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply(b, ocispec.MediaTypeImageManifest)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestPush(t *testing.T) {
+	reg := newTestRegistry(t, "dpkgs")
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/dpkgs:latest"
+	resolver := docker.NewResolver(docker.ResolverOptions{
+		Hosts: docker.ConfigureDefaultRegistries(docker.WithPlainHTTP(docker.MatchAllHosts)),
+	})
+
+	contents := map[string][]byte{}
+	var files []File
+	for _, s := range []string{"foo", "bar"} {
+		b := []byte("blob-" + s)
+		f := File{
+			SHA256:   digest.SHA256.FromBytes(b).Encoded(),
+			Size:     int64(len(b)),
+			Basename: "hello_2.10-2_amd64.deb", // conflicting basenames are allowed
+		}
+		contents[f.SHA256] = b
+		files = append(files, f)
+	}
+	open := func(f File) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(contents[f.SHA256])), nil
+	}
+
+	ctx := context.TODO()
+	desc, err := Push(ctx, resolver, ref, files, open)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, reg.uploads) // 2 files + config
+
+	manifestB, ok := reg.manifests["latest"]
+	assert.Assert(t, ok)
+	assert.Equal(t, desc.Digest, digest.SHA256.FromBytes(manifestB))
+	var manifest ocispec.Manifest
+	assert.NilError(t, json.Unmarshal(manifestB, &manifest))
+	assert.Equal(t, ArtifactType, manifest.ArtifactType)
+	pushed, err := Files(&manifest)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(pushed))
+	for _, f := range pushed {
+		assert.DeepEqual(t, contents[f.SHA256], reg.blobs[digest.NewDigestFromEncoded(digest.SHA256, f.SHA256)])
+	}
+
+	// Existing blobs are skipped
+	desc2, err := Push(ctx, resolver, ref, files, open)
+	assert.NilError(t, err)
+	assert.Equal(t, desc.Digest, desc2.Digest)
+	assert.Equal(t, 3, reg.uploads)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/rpmutil"
 )
 
 type Pacman struct {
@@ -44,3 +46,97 @@ func Split(trimmed string) (*Pacman, error) {
 		Architecture: arch,
 	}, nil
 }
+
+// CompareVersions compares the "[EPOCH:]VERSION-RELEASE" strings in the same way as vercmp(8),
+// and returns -1, 0, or 1.
+func CompareVersions(a, b string) int {
+	aE, aV, aR := rpmutil.SplitEVR(a)
+	bE, bV, bR := rpmutil.SplitEVR(b)
+	if c := rpmvercmp(aE, bE); c != 0 {
+		return c
+	}
+	if c := rpmvercmp(aV, bV); c != 0 {
+		return c
+	}
+	if aR != "" && bR != "" {
+		return rpmvercmp(aR, bR)
+	}
+	return 0
+}
+
+// rpmvercmp is the variant of rpmvercmp() in libalpm.
+// Unlike RPM, "1.0rc1" is older than "1.0".
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	at := func(s string, i int) byte {
+		if i < len(s) {
+			return s[i]
+		}
+		return 0
+	}
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	isAlpha := func(c byte) bool { return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') }
+	isAlnum := func(c byte) bool { return isDigit(c) || isAlpha(c) }
+	i, j := 0, 0
+	prevI, prevJ := 0, 0
+	for i < len(a) && j < len(b) {
+		for i < len(a) && !isAlnum(a[i]) {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) {
+			j++
+		}
+		if i == len(a) || j == len(b) {
+			break
+		}
+		// the number of the separators must be the same
+		if i-prevI != j-prevJ {
+			if i-prevI < j-prevJ {
+				return -1
+			}
+			return 1
+		}
+		prevI, prevJ = i, j
+		isNum := isDigit(a[i])
+		is := isAlpha
+		if isNum {
+			is = isDigit
+		}
+		for i < len(a) && is(a[i]) {
+			i++
+		}
+		for j < len(b) && is(b[j]) {
+			j++
+		}
+		segA, segB := a[prevI:i], b[prevJ:j]
+		if segB == "" {
+			// the segments are different types; numeric is newer
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+		if isNum {
+			segA, segB = strings.TrimLeft(segA, "0"), strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) > len(segB) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+		prevI, prevJ = i, j
+	}
+	if i >= len(a) && j >= len(b) {
+		return 0
+	}
+	if (i >= len(a) && !isAlpha(at(b, j))) || isAlpha(at(a, i)) {
+		return -1
+	}
+	return 1
+}
@@ -16,3 +16,23 @@ func TestParseFilename(t *testing.T) {
 	}
 	assert.DeepEqual(t, expected, got)
 }
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"20220905-1", "20220905-1", 0},
+		{"20220905-1", "20220905-2", -1},
+		{"1.2.3-1", "1.2.10-1", -1},
+		{"1:1.0-1", "2.0-1", 1},
+		{"1.0rc1-1", "1.0-1", -1},
+		{"1.0.a-1", "1.0-1", 1},
+		{"1.0.1-1", "1.0-1", 1},
+		{"1.0-1", "1.0", 0},
+		{"1..0-1", "1.0-1", 1},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, CompareVersions(tc.a, tc.b), "%q vs %q", tc.a, tc.b)
+	}
+}
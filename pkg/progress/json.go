@@ -0,0 +1,48 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JSONProgressInterval is the minimal interval of EventDownloadProgress events per file,
+// emitted by the JSON reporter.
+const JSONProgressInterval = time.Second
+
+// NewJSONReporter returns a reporter that writes an event as a JSON line.
+func NewJSONReporter(w io.Writer) Reporter {
+	return &jsonReporter{
+		enc:          json.NewEncoder(w),
+		lastProgress: make(map[string]time.Time),
+	}
+}
+
+type jsonReporter struct {
+	mu           sync.Mutex
+	enc          *json.Encoder
+	lastProgress map[string]time.Time // key: SHA256
+}
+
+func (r *jsonReporter) Report(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	switch ev.Type {
+	case EventDownloadProgress:
+		if last, ok := r.lastProgress[ev.SHA256]; ok && ev.Time.Sub(last) < JSONProgressInterval {
+			return
+		}
+		r.lastProgress[ev.SHA256] = ev.Time
+	case EventDownloadFinished:
+		delete(r.lastProgress, ev.SHA256)
+	}
+	if err := r.enc.Encode(ev); err != nil {
+		logrus.WithError(err).Warn("Failed to write a progress event")
+	}
+}
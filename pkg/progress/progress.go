@@ -0,0 +1,92 @@
+// Package progress provides the progress reporters for downloading and installing packages.
+package progress
+
+import (
+	"io"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventFileInspected    = EventType("file-inspected")
+	EventCacheHit         = EventType("cache-hit")
+	EventDownloadStarted  = EventType("download-started")
+	EventDownloadProgress = EventType("download-progress")
+	EventDownloadFinished = EventType("download-finished")
+	EventProviderFailover = EventType("provider-failover")
+	EventInstallStarted   = EventType("install-started")
+	EventInstallFinished  = EventType("install-finished")
+)
+
+// Status values of EventFileInspected.
+const (
+	StatusInspectFailed    = "inspect-failed"
+	StatusNotNeeded        = "not-needed"
+	StatusAlreadyInstalled = "already-installed"
+	StatusNeeded           = "needed"
+)
+
+type Event struct {
+	Type       EventType     `json:"Type"`
+	Time       time.Time     `json:"Time"`
+	Index      int           `json:"Index,omitempty"` // 1-based index of the file
+	Total      int           `json:"Total,omitempty"` // Number of the files
+	Name       string        `json:"Name,omitempty"`
+	Basename   string        `json:"Basename,omitempty"`
+	SHA256     string        `json:"SHA256,omitempty"`
+	Status     string        `json:"Status,omitempty"` // For EventFileInspected
+	URL        string        `json:"URL,omitempty"`    // Redacted
+	Bytes      int64         `json:"Bytes,omitempty"`  // Bytes downloaded so far, including the resumed part
+	TotalBytes int64         `json:"TotalBytes,omitempty"`
+	Packages   int           `json:"Packages,omitempty"` // For EventInstallStarted and EventInstallFinished
+	Duration   time.Duration `json:"Duration,omitempty"` // In nanoseconds. For EventDownloadFinished and EventInstallFinished
+	Error      string        `json:"Error,omitempty"`
+}
+
+// Reporter reports events.
+// Reporter must be safe to be called from multiple goroutines.
+type Reporter interface {
+	Report(ev Event)
+}
+
+type ReporterFunc func(ev Event)
+
+func (f ReporterFunc) Report(ev Event) {
+	f(ev)
+}
+
+// Nop is a reporter that discards events.
+var Nop Reporter = ReporterFunc(func(Event) {})
+
+// ProgressInterval is the minimal interval of EventDownloadProgress events emitted by NewProxyReader.
+const ProgressInterval = 200 * time.Millisecond
+
+// NewProxyReader returns a reader that reports EventDownloadProgress events.
+// ev is used as the template of the events; ev.Bytes is the initial number of the bytes.
+func NewProxyReader(r io.Reader, reporter Reporter, ev Event) io.Reader {
+	ev.Type = EventDownloadProgress
+	return &proxyReader{
+		r:        r,
+		reporter: reporter,
+		ev:       ev,
+	}
+}
+
+type proxyReader struct {
+	r        io.Reader
+	reporter Reporter
+	ev       Event
+	last     time.Time
+}
+
+func (pr *proxyReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.ev.Bytes += int64(n)
+	if now := time.Now(); n > 0 && now.Sub(pr.last) >= ProgressInterval {
+		pr.last = now
+		pr.ev.Time = now
+		pr.reporter.Report(pr.ev)
+	}
+	return n, err
+}
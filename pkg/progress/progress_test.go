@@ -0,0 +1,57 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestJSONReporter(t *testing.T) {
+	var b bytes.Buffer
+	r := NewJSONReporter(&b)
+	tm := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	const sha256 = "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
+	r.Report(Event{Type: EventDownloadStarted, Time: tm, SHA256: sha256, TotalBytes: 100})
+	r.Report(Event{Type: EventDownloadProgress, Time: tm.Add(100 * time.Millisecond), SHA256: sha256, Bytes: 10})
+	r.Report(Event{Type: EventDownloadProgress, Time: tm.Add(200 * time.Millisecond), SHA256: sha256, Bytes: 20}) // throttled
+	r.Report(Event{Type: EventDownloadProgress, Time: tm.Add(1500 * time.Millisecond), SHA256: sha256, Bytes: 90})
+	r.Report(Event{Type: EventDownloadFinished, Time: tm.Add(2 * time.Second), SHA256: sha256, Bytes: 100, Duration: 2 * time.Second})
+
+	var events []Event
+	dec := json.NewDecoder(&b)
+	for {
+		var ev Event
+		if err := dec.Decode(&ev); err == io.EOF {
+			break
+		} else {
+			assert.NilError(t, err)
+		}
+		events = append(events, ev)
+	}
+	assert.Equal(t, 4, len(events))
+	assert.Equal(t, EventDownloadStarted, events[0].Type)
+	assert.Equal(t, int64(10), events[1].Bytes)
+	assert.Equal(t, int64(90), events[2].Bytes)
+	assert.Equal(t, EventDownloadFinished, events[3].Type)
+	assert.Equal(t, 2*time.Second, events[3].Duration)
+}
+
+func TestProxyReader(t *testing.T) {
+	var events []Event
+	reporter := ReporterFunc(func(ev Event) {
+		events = append(events, ev)
+	})
+	pr := NewProxyReader(strings.NewReader("hello"), reporter, Event{SHA256: "dummy", Bytes: 3})
+	b, err := io.ReadAll(pr)
+	assert.NilError(t, err)
+	assert.Equal(t, "hello", string(b))
+	assert.Assert(t, len(events) >= 1)
+	assert.Equal(t, EventDownloadProgress, events[0].Type)
+	assert.Equal(t, "dummy", events[0].SHA256)
+	assert.Equal(t, int64(8), events[0].Bytes)
+}
@@ -0,0 +1,85 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/fatih/color"
+	"github.com/reproducible-containers/repro-get/pkg/progressbar"
+	"github.com/sirupsen/logrus"
+)
+
+// NewTextReporter returns a reporter that prints human-readable status lines such as
+// "(001/003) hello_2.10-2_amd64.deb Cached".
+//
+// Progress bars are shown only when bars is true.
+// Progress bars are not readable when multiple files are downloaded in parallel.
+func NewTextReporter(w io.Writer, bars bool) Reporter {
+	return &textReporter{
+		w:                     w,
+		bars:                  bars,
+		markUpProgressCounter: color.New(color.Bold).SprintFunc(),
+		markUpPackage:         color.New(color.FgCyan).SprintFunc(),
+		markUpComment:         color.New(color.FgHiBlack).SprintFunc(),
+		activeBars:            make(map[string]*pb.ProgressBar),
+	}
+}
+
+type textReporter struct {
+	w                     io.Writer
+	bars                  bool
+	markUpProgressCounter func(a ...interface{}) string
+	markUpPackage         func(a ...interface{}) string
+	markUpComment         func(a ...interface{}) string
+
+	mu         sync.Mutex
+	activeBars map[string]*pb.ProgressBar // key: SHA256
+}
+
+func (r *textReporter) printStatus(ev Event, s string, ff ...interface{}) {
+	fmt.Fprintln(r.w, r.markUpProgressCounter(fmt.Sprintf("(%03d/%03d)", ev.Index, ev.Total))+" "+r.markUpPackage(ev.Basename)+" "+r.markUpComment(fmt.Sprintf(s, ff...)))
+}
+
+func (r *textReporter) Report(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch ev.Type {
+	case EventFileInspected:
+		switch ev.Status {
+		case StatusNotNeeded:
+			r.printStatus(ev, "Not needed")
+		case StatusAlreadyInstalled:
+			r.printStatus(ev, "Already installed")
+		}
+	case EventCacheHit:
+		r.printStatus(ev, "Cached")
+	case EventDownloadStarted:
+		r.printStatus(ev, "Downloading from %s", ev.URL)
+		if r.bars {
+			bar, err := progressbar.New(ev.TotalBytes)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to create a progress bar")
+				return
+			}
+			bar.SetCurrent(ev.Bytes)
+			bar.Start()
+			r.activeBars[ev.SHA256] = bar
+		}
+	case EventDownloadProgress:
+		if bar, ok := r.activeBars[ev.SHA256]; ok {
+			bar.SetCurrent(ev.Bytes)
+		}
+	case EventDownloadFinished:
+		if bar, ok := r.activeBars[ev.SHA256]; ok {
+			if ev.Error == "" {
+				bar.SetCurrent(ev.Bytes)
+			}
+			bar.Finish()
+			delete(r.activeBars, ev.SHA256)
+		} else if ev.Error == "" {
+			r.printStatus(ev, "Downloaded")
+		}
+	}
+}
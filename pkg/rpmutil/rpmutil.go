@@ -48,3 +48,131 @@ func Split(trimmed string) (*RPM, error) {
 		Architecture: arch,
 	}, nil
 }
+
+// CompareVersions compares the "VERSION-RELEASE" strings (with an optional "EPOCH:" prefix),
+// and returns -1, 0, or 1.
+func CompareVersions(a, b string) int {
+	aE, aV, aR := SplitEVR(a)
+	bE, bV, bR := SplitEVR(b)
+	if c := Vercmp(aE, bE); c != 0 {
+		return c
+	}
+	if c := Vercmp(aV, bV); c != 0 {
+		return c
+	}
+	return Vercmp(aR, bR)
+}
+
+// SplitEVR splits the "[EPOCH:]VERSION[-RELEASE]" string.
+// The epoch defaults to "0".
+// Also used for pacman, which has the same notation.
+func SplitEVR(s string) (epoch, ver, rel string) {
+	epoch = "0"
+	if i := strings.Index(s, ":"); i >= 0 {
+		epoch, s = s[:i], s[i+1:]
+	}
+	ver = s
+	if i := strings.LastIndex(s, "-"); i >= 0 {
+		ver, rel = s[:i], s[i+1:]
+	}
+	return epoch, ver, rel
+}
+
+// Vercmp compares the version (or release) strings in the same way as rpmvercmp(),
+// and returns -1, 0, or 1.
+//
+// "~" sorts before anything, "^" sorts after the base version but before anything else.
+func Vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	at := func(s string, i int) byte {
+		if i < len(s) {
+			return s[i]
+		}
+		return 0
+	}
+	isAlnum := func(c byte) bool {
+		return isDigit(c) || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isAlnum(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+		if at(a, i) == '~' || at(b, j) == '~' {
+			if at(a, i) != '~' {
+				return 1
+			}
+			if at(b, j) != '~' {
+				return -1
+			}
+			i, j = i+1, j+1
+			continue
+		}
+		if at(a, i) == '^' || at(b, j) == '^' {
+			if i == len(a) {
+				return -1
+			}
+			if j == len(b) {
+				return 1
+			}
+			if a[i] != '^' {
+				return 1
+			}
+			if b[j] != '^' {
+				return -1
+			}
+			i, j = i+1, j+1
+			continue
+		}
+		if i == len(a) || j == len(b) {
+			break
+		}
+		isNum := isDigit(a[i])
+		segment := func(s string, k int) (string, int) {
+			start := k
+			for k < len(s) && ((isNum && isDigit(s[k])) || (!isNum && isAlnum(s[k]) && !isDigit(s[k]))) {
+				k++
+			}
+			return s[start:k], k
+		}
+		var segA, segB string
+		segA, i = segment(a, i)
+		segB, j = segment(b, j)
+		if segB == "" {
+			// the segments are different types; numeric is newer
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+		if isNum {
+			segA, segB = strings.TrimLeft(segA, "0"), strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) > len(segB) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i >= len(a):
+		return -1
+	default:
+		return 1
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -29,3 +29,26 @@ func TestSplit(t *testing.T) {
 	}
 	assert.DeepEqual(t, expected, got)
 }
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"2022.2.54-5.fc37", "2022.2.54-5.fc37", 0},
+		{"2022.2.54-5.fc37", "2022.2.54-10.fc37", -1},
+		{"2022.2.54-5.fc37", "2021.2.52-10.fc37", 1},
+		{"1.0-1", "1.0a-1", -1},
+		{"1.0~rc1-1", "1.0-1", -1},
+		{"1.0^git1-1", "1.0-1", 1},
+		{"1.0^git1-1", "1.0.1-1", -1},
+		{"1.010-1", "1.9-1", 1},
+		{"1.0-1", "1.0.0-1", -1},
+		{"1:1.0-1", "2.0-1", 1},
+		{"1.0_1-1", "1.0.1-1", 0},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, CompareVersions(tc.a, tc.b), "%q vs %q", tc.a, tc.b)
+		assert.Equal(t, -tc.expected, CompareVersions(tc.b, tc.a), "%q vs %q", tc.b, tc.a)
+	}
+}
@@ -0,0 +1,192 @@
+// Package sshsig implements the SSH signature format ("SSHSIG") for detached signatures,
+// compatible with `ssh-keygen -Y sign` and `ssh-keygen -Y verify`.
+//
+// Only ed25519 keys are supported.
+//
+// See https://github.com/openssh/openssh-portable/blob/V_9_4_P1/PROTOCOL.sshsig
+package sshsig
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// Namespace is the default namespace for signing hash files.
+	// Corresponds to `ssh-keygen -Y sign -n repro-get`.
+	Namespace = "repro-get"
+
+	magicPreamble = "SSHSIG"
+	sigVersion    = 1
+	hashAlgorithm = "sha512"
+	pemType       = "SSH SIGNATURE"
+)
+
+// ErrUntrustedKey is returned by Verify when the signature is valid but the key is not trusted.
+var ErrUntrustedKey = errors.New("the signature is not made by a trusted key")
+
+// wrappedSig is the signature blob.
+type wrappedSig struct {
+	MagicPreamble [6]byte
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// signedData is the data to be signed.
+type signedData struct {
+	MagicPreamble [6]byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+func messageToSign(r io.Reader, namespace string) ([]byte, error) {
+	if namespace == "" {
+		return nil, errors.New("namespace must not be empty")
+	}
+	h := sha512.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	sd := signedData{
+		Namespace:     namespace,
+		HashAlgorithm: hashAlgorithm,
+		Hash:          h.Sum(nil),
+	}
+	copy(sd.MagicPreamble[:], magicPreamble)
+	return ssh.Marshal(sd), nil
+}
+
+func checkKeyType(pub ssh.PublicKey) error {
+	if typ := pub.Type(); typ != ssh.KeyAlgoED25519 {
+		return fmt.Errorf("unsupported key type %q (expected %q)", typ, ssh.KeyAlgoED25519)
+	}
+	return nil
+}
+
+// Sign signs the content of r, and returns the armored signature ("-----BEGIN SSH SIGNATURE-----").
+func Sign(signer ssh.Signer, r io.Reader, namespace string) ([]byte, error) {
+	if err := checkKeyType(signer.PublicKey()); err != nil {
+		return nil, err
+	}
+	msg, err := messageToSign(r, namespace)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.Sign(nil, msg)
+	if err != nil {
+		return nil, err
+	}
+	ws := wrappedSig{
+		Version:       sigVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: hashAlgorithm,
+		Signature:     ssh.Marshal(sig),
+	}
+	copy(ws.MagicPreamble[:], magicPreamble)
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  pemType,
+		Bytes: ssh.Marshal(ws),
+	}), nil
+}
+
+// Verify verifies the armored signature of the content of r, and returns the public key that made the signature.
+// The public key must be one of the trusted keys.
+func Verify(r io.Reader, armored []byte, namespace string, trusted []ssh.PublicKey) (ssh.PublicKey, error) {
+	block, _ := pem.Decode(armored)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("expected a PEM block %q", pemType)
+	}
+	var ws wrappedSig
+	if err := ssh.Unmarshal(block.Bytes, &ws); err != nil {
+		return nil, fmt.Errorf("failed to parse the signature: %w", err)
+	}
+	if string(ws.MagicPreamble[:]) != magicPreamble {
+		return nil, fmt.Errorf("unexpected magic preamble %q", ws.MagicPreamble[:])
+	}
+	if ws.Version != sigVersion {
+		return nil, fmt.Errorf("unsupported signature version %d", ws.Version)
+	}
+	if ws.Namespace != namespace {
+		return nil, fmt.Errorf("expected namespace %q, got %q", namespace, ws.Namespace)
+	}
+	if ws.HashAlgorithm != hashAlgorithm {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", ws.HashAlgorithm)
+	}
+	pub, err := ssh.ParsePublicKey(ws.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the public key in the signature: %w", err)
+	}
+	if err = checkKeyType(pub); err != nil {
+		return nil, err
+	}
+	var sig ssh.Signature
+	if err = ssh.Unmarshal(ws.Signature, &sig); err != nil {
+		return nil, fmt.Errorf("failed to parse the signature: %w", err)
+	}
+	msg, err := messageToSign(r, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err = pub.Verify(msg, &sig); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	for _, f := range trusted {
+		if bytes.Equal(f.Marshal(), pub.Marshal()) {
+			return pub, nil
+		}
+	}
+	return pub, fmt.Errorf("%w (%s)", ErrUntrustedKey, ssh.FingerprintSHA256(pub))
+}
+
+// ParsePublicKeys parses the public keys in the authorized_keys format ("ssh-ed25519 AAAA... comment"),
+// or in the allowed_signers format of ssh-keygen ("principal ssh-ed25519 AAAA...").
+// Empty lines and comment lines are ignored.
+func ParsePublicKeys(b []byte) ([]ssh.PublicKey, error) {
+	var res []ssh.PublicKey
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for i := 0; sc.Scan(); i++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			// Retry as an allowed_signers line, by skipping the principal field
+			if fields := strings.Fields(line); len(fields) > 1 {
+				var err2 error
+				pub, _, _, _, err2 = ssh.ParseAuthorizedKey([]byte(strings.Join(fields[1:], " ")))
+				if err2 == nil {
+					err = nil
+				}
+			}
+		}
+		if err != nil {
+			return res, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if err = checkKeyType(pub); err != nil {
+			return res, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		res = append(res, pub)
+	}
+	return res, sc.Err()
+}
+
+// Fingerprint returns the SHA256 fingerprint of the public key, e.g., "SHA256:AbCd...".
+func Fingerprint(pub ssh.PublicKey) string {
+	return ssh.FingerprintSHA256(pub)
+}
@@ -0,0 +1,113 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+)
+
+func newTestSigner(t testing.TB) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	assert.NilError(t, err)
+	return signer
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	otherSigner := newTestSigner(t)
+	content := []byte("d6f1ae5e0ad8d5a7a5b1c2e7e1e5c1a1d6f1ae5e0ad8d5a7a5b1c2e7e1e5c1a1  hello_2.10-2_amd64.deb\n")
+
+	sig, err := Sign(signer, bytes.NewReader(content), Namespace)
+	assert.NilError(t, err)
+	assert.Check(t, strings.HasPrefix(string(sig), "-----BEGIN SSH SIGNATURE-----\n"))
+
+	trusted := []ssh.PublicKey{otherSigner.PublicKey(), signer.PublicKey()}
+	pub, err := Verify(bytes.NewReader(content), sig, Namespace, trusted)
+	assert.NilError(t, err)
+	assert.Equal(t, Fingerprint(signer.PublicKey()), Fingerprint(pub))
+
+	tampered := bytes.Replace(content, []byte("2.10-2"), []byte("2.10-3"), 1)
+	_, err = Verify(bytes.NewReader(tampered), sig, Namespace, trusted)
+	assert.ErrorContains(t, err, "invalid signature")
+
+	_, err = Verify(bytes.NewReader(content), sig, "file", trusted)
+	assert.ErrorContains(t, err, "expected namespace")
+
+	_, err = Verify(bytes.NewReader(content), sig, Namespace, []ssh.PublicKey{otherSigner.PublicKey()})
+	assert.Check(t, errors.Is(err, ErrUntrustedKey), err)
+
+	_, err = Verify(bytes.NewReader(content), []byte("foo"), Namespace, trusted)
+	assert.ErrorContains(t, err, "expected a PEM block")
+}
+
+func TestParsePublicKeys(t *testing.T) {
+	signer := newTestSigner(t)
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	s := "# comment\n\n" + authorizedKey + " user@example.com\n" + "user@example.com " + authorizedKey + "\n"
+	keys, err := ParsePublicKeys([]byte(s))
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(keys))
+	for _, k := range keys {
+		assert.Equal(t, Fingerprint(signer.PublicKey()), Fingerprint(k))
+	}
+
+	_, err = ParsePublicKeys([]byte("foo bar\n"))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+// TestSSHKeygen tests the compatibility with `ssh-keygen -Y`.
+func TestSSHKeygen(t *testing.T) {
+	sshKeygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	assert.NilError(t, exec.Command(sshKeygen, "-q", "-t", "ed25519", "-N", "", "-f", keyFile).Run())
+	keyB, err := os.ReadFile(keyFile)
+	assert.NilError(t, err)
+	signer, err := ssh.ParsePrivateKey(keyB)
+	assert.NilError(t, err)
+	pubB, err := os.ReadFile(keyFile + ".pub")
+	assert.NilError(t, err)
+	trusted, err := ParsePublicKeys(pubB)
+	assert.NilError(t, err)
+
+	contentFile := filepath.Join(dir, "SHA256SUMS")
+	content := []byte("d6f1ae5e0ad8d5a7a5b1c2e7e1e5c1a1d6f1ae5e0ad8d5a7a5b1c2e7e1e5c1a1  hello_2.10-2_amd64.deb\n")
+	assert.NilError(t, os.WriteFile(contentFile, content, 0644))
+
+	t.Run("ssh-keygen sign, Verify", func(t *testing.T) {
+		out, err := exec.Command(sshKeygen, "-Y", "sign", "-f", keyFile, "-n", Namespace, contentFile).CombinedOutput()
+		assert.NilError(t, err, string(out))
+		sig, err := os.ReadFile(contentFile + ".sig")
+		assert.NilError(t, err)
+		_, err = Verify(bytes.NewReader(content), sig, Namespace, trusted)
+		assert.NilError(t, err)
+	})
+
+	t.Run("Sign, ssh-keygen verify", func(t *testing.T) {
+		sig, err := Sign(signer, bytes.NewReader(content), Namespace)
+		assert.NilError(t, err)
+		sigFile := filepath.Join(dir, "go.sig")
+		assert.NilError(t, os.WriteFile(sigFile, sig, 0644))
+		allowedSignersFile := filepath.Join(dir, "allowed_signers")
+		assert.NilError(t, os.WriteFile(allowedSignersFile, append([]byte("foo@example.com "), pubB...), 0644))
+		cmd := exec.Command(sshKeygen, "-Y", "verify", "-f", allowedSignersFile, "-I", "foo@example.com", "-n", Namespace, "-s", sigFile)
+		cmd.Stdin = bytes.NewReader(content)
+		out, err := cmd.CombinedOutput()
+		assert.NilError(t, err, string(out))
+	})
+}
@@ -0,0 +1,98 @@
+package urlopener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// HTTPStatusError is returned when an HTTP server responds with an unexpected status.
+type HTTPStatusError struct {
+	URL        string // redacted
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // parsed from the Retry-After header, zero if absent
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("expected HTTP status %d for %q, got %s", http.StatusOK, e.URL, e.Status)
+}
+
+func newHTTPStatusError(u *url.URL, resp *http.Response) *HTTPStatusError {
+	return &HTTPStatusError{
+		URL:        Redacted(u),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter parses the Retry-After header value, which is either seconds or an HTTP date.
+func parseRetryAfter(s string, now time.Time) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(s); err == nil {
+		if sec < 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+	if tm, err := http.ParseTime(s); err == nil {
+		if d := tm.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// IsTransient returns true if the error is likely to be resolved by retrying the same URL,
+// e.g., a connection reset, a timeout, HTTP 429, or HTTP 5xx.
+//
+// A refused connection is not transient, so that the caller can fail over to the next provider
+// without waiting for retries against a dead mirror.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return statusErr.StatusCode >= 500
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RetryAfter returns the duration specified in the Retry-After header of the HTTP response
+// that caused the error.
+func RetryAfter(err error) (time.Duration, bool) {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter, true
+	}
+	return 0, false
+}
@@ -0,0 +1,46 @@
+package urlopener
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestIsTransient(t *testing.T) {
+	type testCase struct {
+		err      error
+		expected bool
+	}
+	testCases := []testCase{
+		{err: nil, expected: false},
+		{err: &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}, expected: true},
+		{err: &HTTPStatusError{StatusCode: http.StatusTooManyRequests}, expected: true},
+		{err: &HTTPStatusError{StatusCode: http.StatusNotFound}, expected: false},
+		{err: fmt.Errorf("failed to copy: %w", io.ErrUnexpectedEOF), expected: true},
+		{err: fmt.Errorf("failed to copy: %w", syscall.ECONNRESET), expected: true},
+		{err: fmt.Errorf("failed to open: %w", context.Canceled), expected: false},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, expected: false},
+		{err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, expected: true},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "example.com"}}, expected: false},
+		{err: fmt.Errorf("expected sha256sum %q, got %q", "foo", "bar"), expected: false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, IsTransient(tc.err), "%v", tc.err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("invalid", now))
+}
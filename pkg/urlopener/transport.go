@@ -0,0 +1,99 @@
+package urlopener
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TLSOpts specifies the TLS configuration for HTTPS URLs and OCI registries.
+type TLSOpts struct {
+	CACertFiles []string // PEM files, appended to the system cert pool
+	CertFile    string   // PEM file of the client certificate
+	KeyFile     string   // PEM file of the client key
+}
+
+// NewTLSConfig creates a TLS config from the options.
+func NewTLSConfig(o TLSOpts) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if len(o.CACertFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the system cert pool: %w", err)
+		}
+		for _, f := range o.CACertFiles {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(b) {
+				return nil, fmt.Errorf("failed to load CA certs from %q", f)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	switch {
+	case o.CertFile != "" && o.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate %q (key %q): %w", o.CertFile, o.KeyFile, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case o.CertFile != "" || o.KeyFile != "":
+		return nil, errors.New("the client certificate and the client key must be specified together")
+	}
+	return cfg, nil
+}
+
+// WithTLSConfig specifies the TLS config for HTTPS URLs and OCI registries.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *URLOpener) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithProxy specifies the proxy for HTTP(S) URLs and OCI registries.
+// The proxy is used for both HTTP and HTTPS.
+// Empty values fall back to the environment variables ($HTTP_PROXY, $HTTPS_PROXY, $NO_PROXY).
+func WithProxy(proxy, noProxy string) Option {
+	return func(o *URLOpener) {
+		cfg := httpproxy.FromEnvironment()
+		if proxy != "" {
+			cfg.HTTPProxy = proxy
+			cfg.HTTPSProxy = proxy
+		}
+		if noProxy != "" {
+			cfg.NoProxy = noProxy
+		}
+		proxyFunc := cfg.ProxyFunc()
+		o.proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+}
+
+func (o *URLOpener) newHTTPClient() *http.Client {
+	if o.tlsConfig == nil && o.proxy == nil {
+		return http.DefaultClient
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	o.updateTransport(tr)
+	return &http.Client{
+		Transport: tr,
+	}
+}
+
+func (o *URLOpener) updateTransport(tr *http.Transport) {
+	if o.tlsConfig != nil {
+		tr.TLSClientConfig = o.tlsConfig.Clone()
+	}
+	if o.proxy != nil {
+		tr.Proxy = o.proxy
+	}
+}
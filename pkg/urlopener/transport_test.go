@@ -0,0 +1,66 @@
+package urlopener
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestOpenWithCACert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+
+	_, _, err = New().Open(context.TODO(), u, "")
+	assert.ErrorContains(t, err, "certificate")
+
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.NilError(t, os.WriteFile(caCertFile, caCert, 0644))
+	tlsConfig, err := NewTLSConfig(TLSOpts{CACertFiles: []string{caCertFile}})
+	assert.NilError(t, err)
+
+	r, _, err := New(WithTLSConfig(tlsConfig)).Open(context.TODO(), u, "")
+	assert.NilError(t, err)
+	b, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.NilError(t, r.Close())
+	assert.Equal(t, "ok", string(b))
+
+	_, err = NewTLSConfig(TLSOpts{CertFile: caCertFile})
+	assert.ErrorContains(t, err, "must be specified together")
+}
+
+func TestOpenWithProxy(t *testing.T) {
+	var proxied []string
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(proxySrv.Close)
+
+	o := New(WithProxy(proxySrv.URL, "no-proxy.example.com"))
+	u, err := url.Parse("http://example.com/foo")
+	assert.NilError(t, err)
+	r, _, err := o.Open(context.TODO(), u, "")
+	assert.NilError(t, err)
+	assert.NilError(t, r.Close())
+	assert.DeepEqual(t, []string{"http://example.com/foo"}, proxied)
+
+	u, err = url.Parse("http://no-proxy.example.com/foo")
+	assert.NilError(t, err)
+	_, _, err = o.Open(context.TODO(), u, "")
+	assert.Check(t, err != nil)
+	assert.Equal(t, 1, len(proxied))
+}